@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+type WalkOptions struct {
+	// glob patterns, file is included if it matches any include pattern (or include is empty)
+	Include []string
+	// glob patterns, file or directory is excluded if it matches any exclude pattern
+	Exclude        []string
+	FollowSymlinks bool
+}
+
+// findFiles walks directory recursively and returns files matching walk options. Patterns are matched against file
+// name and against path relative to the root directory.
+func findFiles(root string, options WalkOptions) ([]string, error) {
+
+	if _, err := os.Stat(root); err != nil {
+		return nil, err
+	}
+	visited := make(map[string]struct{})
+	return walkDir(root, root, options, visited)
+}
+
+func walkDir(root, dir string, options WalkOptions, visited map[string]struct{}) ([]string, error) {
+
+	// guard against symlink loops
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := visited[realDir]; ok {
+		return nil, nil
+	}
+	visited[realDir] = struct{}{}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if matchAny(options.Exclude, root, path) {
+			continue
+		}
+
+		info, err := entryInfo(entry, path, options.FollowSymlinks)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("walk %s: %v", path, err))
+			continue
+		}
+		if info == nil {
+			// symlink and we are not following symlinks
+			continue
+		}
+
+		if info.IsDir() {
+			dirFiles, err := walkDir(root, path, options, visited)
+			if err != nil {
+				slog.Debug(fmt.Sprintf("walk %s: %v", path, err))
+				continue
+			}
+			files = append(files, dirFiles...)
+			continue
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		if len(options.Include) != 0 && !matchAny(options.Include, root, path) {
+			continue
+		}
+		files = append(files, path)
+	}
+	return files, nil
+}
+
+// entryInfo returns file info, symlinks are resolved only if follow symlinks is set, otherwise nil is returned
+func entryInfo(entry fs.DirEntry, path string, followSymlinks bool) (fs.FileInfo, error) {
+
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return entry.Info()
+	}
+	if !followSymlinks {
+		return nil, nil
+	}
+	return os.Stat(path)
+}
+
+func matchAny(patterns []string, root, path string) bool {
+
+	relativePath, err := filepath.Rel(root, path)
+	if err != nil {
+		relativePath = path
+	}
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		if ok, _ := filepath.Match(pattern, filepath.Base(path)); ok {
+			return true
+		}
+		ok, _ := filepath.Match(pattern, relativePath)
+		return ok
+	})
+}
+
+func isDirectory(arg string) bool {
+
+	info, err := os.Stat(arg)
+	if err != nil {
+		return false
+	}
+	return info.IsDir()
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_findFiles(t *testing.T) {
+
+	t.Run("given nested directories then all files are returned", func(t *testing.T) {
+
+		root := createTestTree(t)
+		files, err := findFiles(root, WalkOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(root, "a.pem"),
+			filepath.Join(root, "b.crt"),
+			filepath.Join(root, "nested", "c.pem"),
+			filepath.Join(root, "private", "key.pem"),
+		}, files)
+	})
+
+	t.Run("given include and exclude patterns then only matching files are returned", func(t *testing.T) {
+
+		root := createTestTree(t)
+		files, err := findFiles(root, WalkOptions{Include: []string{"*.pem"}, Exclude: []string{"private"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(root, "a.pem"),
+			filepath.Join(root, "nested", "c.pem"),
+		}, files)
+	})
+
+	t.Run("given pattern with relative path then matching files are returned", func(t *testing.T) {
+
+		root := createTestTree(t)
+		files, err := findFiles(root, WalkOptions{Include: []string{"nested/*"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{filepath.Join(root, "nested", "c.pem")}, files)
+	})
+
+	t.Run("given symlinks and follow symlinks is not set then symlinks are skipped", func(t *testing.T) {
+
+		root := createTestTree(t)
+		require.NoError(t, os.Symlink(filepath.Join(root, "nested"), filepath.Join(root, "link")))
+		require.NoError(t, os.Symlink(filepath.Join(root, "a.pem"), filepath.Join(root, "link.pem")))
+
+		files, err := findFiles(root, WalkOptions{Include: []string{"*.pem"}, Exclude: []string{"private"}})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(root, "a.pem"),
+			filepath.Join(root, "nested", "c.pem"),
+		}, files)
+	})
+
+	t.Run("given symlinks and follow symlinks is set then symlinks are followed and loops are ignored", func(t *testing.T) {
+
+		root := createTestTree(t)
+		require.NoError(t, os.Symlink(filepath.Join(root, "a.pem"), filepath.Join(root, "link.pem")))
+		require.NoError(t, os.Symlink(root, filepath.Join(root, "nested", "loop")))
+
+		files, err := findFiles(root, WalkOptions{Include: []string{"*.pem"}, Exclude: []string{"private"}, FollowSymlinks: true})
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(root, "a.pem"),
+			filepath.Join(root, "link.pem"),
+			filepath.Join(root, "nested", "c.pem"),
+		}, files)
+	})
+}
+
+func createTestTree(t *testing.T) string {
+
+	root := t.TempDir()
+	for _, file := range []string{"a.pem", "b.crt", "nested/c.pem", "private/key.pem"} {
+		path := filepath.Join(root, file)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0700))
+		require.NoError(t, os.WriteFile(path, []byte(file), 0600))
+	}
+	return root
+}
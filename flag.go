@@ -1,36 +1,69 @@
 package main
 
 import (
+	"crypto/tls"
+	"errors"
 	"flag"
 	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Flags struct {
-	Usage       func()
-	Expiry      bool
-	NoDuplicate bool
-	NoExpired   bool
-	SortExpiry  bool
-	SubjectLike string
-	IssuerLike  string
-	ServerName  string
-	Insecure    bool
-	Chains      bool
-	Extensions  bool
-	Signature   bool
-	Pem         bool
-	PemOnly     bool
-	Verbose     bool
-	Version     bool
-	More        bool
-	Args        []string
+	Usage            func()
+	Expiry           bool
+	NoDuplicate      bool
+	NoExpired        bool
+	SortExpiry       bool
+	SubjectLike      string
+	IssuerLike       string
+	ServerName       string
+	Insecure         bool
+	StartTLS         string
+	DefaultPort      string
+	ConnectTimeout   time.Duration
+	HandshakeTimeout time.Duration
+	Deadline         time.Duration
+	Proxy            string
+	Resolve          map[string]string
+	ClientCert       *tls.Certificate
+	Trust            cert.Trust
+	AllIPs           bool
+	ALPN             []string
+	Scan             bool
+	PQ               bool
+	Revocation       string
+	Password         string
+	WalkOptions      WalkOptions
+	Targets          []TargetEntry
+	Pool             PoolOptions
+	Ordered          bool
+	GroupBy          string
+	Chains           bool
+	Extensions       bool
+	Signature        bool
+	Pem              bool
+	PemOnly          bool
+	Verbose          bool
+	Version          bool
+	More             bool
+	Args             []string
 }
 
 func ParseFlags() (Flags, error) {
 
 	var flags Flags
+	for _, resolve := range splitList(getStringEnv("CERTINFO_RESOLVE", "")) {
+		if err := flags.addResolve(resolve); err != nil {
+			return Flags{}, err
+		}
+	}
+	var passwordFile, include, exclude, targetsFile, clientCert, clientKey, clientPassword, alpn string
+	var caFile, caDir, intermediates string
+	var caReplace bool
 	flagSet := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
 	flagSet.BoolVar(&flags.Expiry, "expiry", getBoolEnv("CERTINFO_EXPIRY", false),
 		"print expiry of certificates")
@@ -48,6 +81,76 @@ func ParseFlags() (Flags, error) {
 		"verify the hostname on the returned certificates, useful for testing SNI")
 	flagSet.BoolVar(&flags.Insecure, "insecure", getBoolEnv("CERTINFO_INSECURE", false),
 		"whether a client verifies the server's certificate chain and host name (only applicable for host)")
+	flagSet.StringVar(&flags.StartTLS, "starttls", getStringEnv("CERTINFO_STARTTLS", cert.StartTLSAuto),
+		"protocol to upgrade plain text connection to TLS - smtp, imap, pop3, ldap, xmpp, ftp, nntp, postgres, mysql, rdp,\n"+
+			"mssql, none or auto (by well-known port)")
+	flagSet.StringVar(&flags.DefaultPort, "default-port", getStringEnv("CERTINFO_DEFAULT_PORT", "443"),
+		"port used for host arguments without port")
+	flagSet.DurationVar(&flags.ConnectTimeout, "connect-timeout", getDurationEnv("CERTINFO_CONNECT_TIMEOUT", 5*time.Second),
+		"TCP connect timeout for network targets")
+	flagSet.DurationVar(&flags.HandshakeTimeout, "handshake-timeout", getDurationEnv("CERTINFO_HANDSHAKE_TIMEOUT", 5*time.Second),
+		"protocol upgrade and TLS handshake timeout for network targets")
+	flagSet.DurationVar(&flags.Deadline, "deadline", getDurationEnv("CERTINFO_DEADLINE", 0),
+		"deadline for the whole run, targets not loaded by the deadline are reported as cancelled, 0 is no deadline")
+	flagSet.StringVar(&flags.Proxy, "proxy", getStringEnv("CERTINFO_PROXY", ""),
+		"http, socks5 or socks5h (proxy resolves host) proxy URL for network targets e.g. socks5://proxy:1080,\n"+
+			"HTTPS_PROXY and NO_PROXY environment variables are used if it is not set, none connects directly")
+	flagSet.Func("resolve", "connect to IP instead of resolving the host, SNI and hostname verification use the host,\n"+
+		"host:port:ip format, can be set multiple times", func(value string) error {
+		return flags.addResolve(value)
+	})
+	flagSet.BoolVar(&flags.AllIPs, "all-ips", getBoolEnv("CERTINFO_ALL_IPS", false),
+		"connect to every resolved IP of the host and mark IPs serving different certificate")
+	flagSet.StringVar(&alpn, "alpn", getStringEnv("CERTINFO_ALPN", ""),
+		"comma separated application protocols offered by ALPN in direct TLS handshake e.g. 'h2,http/1.1', ALPN is\n"+
+			"not offered if it is not set")
+	flagSet.BoolVar(&flags.Scan, "scan", getBoolEnv("CERTINFO_SCAN", false),
+		"enumerate TLS versions and cipher suites accepted by network targets, check server cipher preference and grade")
+	flagSet.StringVar(&flags.Revocation, "revocation", getStringEnv("CERTINFO_REVOCATION", cert.RevocationNone),
+		"online revocation check of certificates with issuer in the chain, none or ocsp (OCSP responders from AIA)")
+	flagSet.BoolVar(&flags.PQ, "pq", getBoolEnv("CERTINFO_PQ", false),
+		"probe post-quantum hybrid (X25519MLKEM768) and classical key exchange groups accepted by network targets and\n"+
+			"print summary of post-quantum readiness")
+	flagSet.StringVar(&clientCert, "client-cert", getStringEnv("CERTINFO_CLIENT_CERT", ""),
+		"client certificate for mutual TLS, PEM (with key or -client-key) or PKCS#12 file")
+	flagSet.StringVar(&clientKey, "client-key", getStringEnv("CERTINFO_CLIENT_KEY", ""),
+		"client certificate PEM private key file")
+	flagSet.StringVar(&clientPassword, "client-password", getStringEnv("CERTINFO_CLIENT_PASSWORD", ""),
+		"password for PKCS#12 client certificate")
+	flagSet.StringVar(&caFile, "ca-file", getStringEnv("CERTINFO_CA_FILE", ""),
+		"file with trusted root certificates used for chains and host verification")
+	flagSet.StringVar(&caDir, "ca-dir", getStringEnv("CERTINFO_CA_DIR", ""),
+		"directory with trusted root certificate files used for chains and host verification")
+	flagSet.BoolVar(&caReplace, "ca-replace", getBoolEnv("CERTINFO_CA_REPLACE", false),
+		"replace system roots with ca-file and ca-dir certificates, otherwise they are added to system roots")
+	flagSet.StringVar(&intermediates, "intermediates", getStringEnv("CERTINFO_INTERMEDIATES", ""),
+		"file with additional intermediate certificates used for chains and host verification")
+	flagSet.StringVar(&flags.Password, "password", getStringEnv("CERTINFO_PASSWORD", ""),
+		"password for keystores (PKCS#12, JKS), optional for JKS integrity check")
+	flagSet.StringVar(&passwordFile, "password-file", getStringEnv("CERTINFO_PASSWORD_FILE", ""),
+		"file with password for keystores (PKCS#12, JKS)")
+	flagSet.StringVar(&include, "include", getStringEnv("CERTINFO_INCLUDE", ""),
+		"comma separated glob patterns of files to include when walking directories e.g. '*.pem,*.crt'")
+	flagSet.StringVar(&exclude, "exclude", getStringEnv("CERTINFO_EXCLUDE", ""),
+		"comma separated glob patterns of files and directories to exclude when walking directories")
+	flagSet.BoolVar(&flags.WalkOptions.FollowSymlinks, "follow-symlinks", getBoolEnv("CERTINFO_FOLLOW_SYMLINKS", false),
+		"whether to follow symlinks when walking directories")
+	flagSet.StringVar(&targetsFile, "targets", getStringEnv("CERTINFO_TARGETS", ""),
+		"YAML or JSON file with targets (address, sni, insecure, protocol, timeout, hostnames and labels)")
+	flagSet.StringVar(&flags.GroupBy, "group-by", getStringEnv("CERTINFO_GROUP_BY", ""),
+		"label to group certificates by (only applicable for expiry)")
+	flagSet.IntVar(&flags.Pool.Concurrency, "concurrency", getIntEnv("CERTINFO_CONCURRENCY", 32),
+		"maximum number of targets loaded in parallel")
+	flagSet.IntVar(&flags.Pool.PerHost, "per-host", getIntEnv("CERTINFO_PER_HOST", 4),
+		"maximum number of parallel connections to the same host, 0 is no limit")
+	flagSet.IntVar(&flags.Pool.Retries, "retries", getIntEnv("CERTINFO_RETRIES", 2),
+		"number of retries of transient connect errors (timeouts, refused and reset connections)")
+	flagSet.DurationVar(&flags.Pool.RetryBackoff, "retry-backoff", getDurationEnv("CERTINFO_RETRY_BACKOFF", 500*time.Millisecond),
+		"initial backoff between retries, it doubles with every retry")
+	flagSet.Float64Var(&flags.Pool.RequestsPerSecond, "rps", getFloat64Env("CERTINFO_RPS", 0),
+		"maximum number of new connections per second, 0 is no limit")
+	flagSet.BoolVar(&flags.Ordered, "ordered", getBoolEnv("CERTINFO_ORDERED", false),
+		"print results in argument order, otherwise results are printed as soon as they are loaded")
 	flagSet.BoolVar(&flags.Chains, "chains", getBoolEnv("CERTINFO_CHAINS", false),
 		"whether to print verified chains as well (only applicable for host)")
 	flagSet.BoolVar(&flags.Extensions, "extensions", getBoolEnv("CERTINFO_EXTENSIONS", false),
@@ -65,7 +168,7 @@ func ParseFlags() (Flags, error) {
 	flagSet.BoolVar(&flags.More, "more", getBoolEnv("CERTINFO_MORE", false), "combination of '-pem -signature -chains'")
 
 	flagSet.Usage = func() {
-		fmt.Fprint(flagSet.Output(), "Usage: certinfo [flags] [<file>|<host:port> ...]\n")
+		fmt.Fprint(flagSet.Output(), "Usage: certinfo [flags] [<file>|<directory>|<host[:port]>|<url> ...]\n")
 		flagSet.PrintDefaults()
 	}
 	flags.Usage = flagSet.Usage
@@ -74,6 +177,48 @@ func ParseFlags() (Flags, error) {
 		return Flags{}, err
 	}
 	flags.Args = flagSet.Args()
+	flags.WalkOptions.Include = splitList(include)
+	flags.WalkOptions.Exclude = splitList(exclude)
+	flags.ALPN = splitList(alpn)
+
+	if passwordFile != "" {
+		if flags.Password != "" {
+			return Flags{}, errors.New("only one of password or password-file can be set")
+		}
+		password, err := readPasswordFile(passwordFile)
+		if err != nil {
+			return Flags{}, err
+		}
+		flags.Password = password
+	}
+
+	if flags.Revocation != cert.RevocationNone && flags.Revocation != cert.RevocationOCSP {
+		return Flags{}, fmt.Errorf("invalid revocation %s: expected none or ocsp", flags.Revocation)
+	}
+
+	trust, err := cert.LoadTrust(caFile, caDir, intermediates, caReplace)
+	if err != nil {
+		return Flags{}, err
+	}
+	flags.Trust = trust
+
+	if clientCert != "" {
+		certificate, err := cert.LoadClientCertificate(clientCert, clientKey, clientPassword)
+		if err != nil {
+			return Flags{}, err
+		}
+		flags.ClientCert = &certificate
+	} else if clientKey != "" {
+		return Flags{}, errors.New("client-key requires client-cert")
+	}
+
+	if targetsFile != "" {
+		targets, err := readTargetsFile(targetsFile)
+		if err != nil {
+			return Flags{}, err
+		}
+		flags.Targets = targets
+	}
 
 	// Combination of flags
 	if flags.More {
@@ -85,6 +230,37 @@ func ParseFlags() (Flags, error) {
 	return flags, nil
 }
 
+func (f Flags) NetworkOptions() cert.NetworkOptions {
+
+	return cert.NetworkOptions{
+		ServerName:         f.ServerName,
+		InsecureSkipVerify: f.Insecure,
+		StartTLS:           f.StartTLS,
+		ConnectTimeout:     f.ConnectTimeout,
+		HandshakeTimeout:   f.HandshakeTimeout,
+		Proxy:              f.Proxy,
+		ClientCertificate:  f.ClientCert,
+		Trust:              f.Trust,
+		NextProtos:         f.ALPN,
+		Scan:               f.Scan,
+		KeyExchange:        f.PQ,
+	}
+}
+
+// addResolve adds resolve override in host:port:ip format
+func (f *Flags) addResolve(value string) error {
+
+	addr, ip, err := parseResolve(value)
+	if err != nil {
+		return err
+	}
+	if f.Resolve == nil {
+		f.Resolve = make(map[string]string)
+	}
+	f.Resolve[addr] = ip
+	return nil
+}
+
 func getStringEnv(envName string, defaultValue string) string {
 
 	if env, ok := os.LookupEnv(envName); ok {
@@ -105,3 +281,64 @@ func getBoolEnv(envName string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getIntEnv(envName string, defaultValue int) int {
+
+	env, ok := os.LookupEnv(envName)
+	if !ok {
+		return defaultValue
+	}
+
+	if intValue, err := strconv.Atoi(env); err == nil {
+		return intValue
+	}
+	return defaultValue
+}
+
+func getFloat64Env(envName string, defaultValue float64) float64 {
+
+	env, ok := os.LookupEnv(envName)
+	if !ok {
+		return defaultValue
+	}
+
+	if floatValue, err := strconv.ParseFloat(env, 64); err == nil {
+		return floatValue
+	}
+	return defaultValue
+}
+
+func getDurationEnv(envName string, defaultValue time.Duration) time.Duration {
+
+	env, ok := os.LookupEnv(envName)
+	if !ok {
+		return defaultValue
+	}
+
+	if durationValue, err := time.ParseDuration(env); err == nil {
+		return durationValue
+	}
+	return defaultValue
+}
+
+// splitList splits comma separated list, empty items are removed
+func splitList(in string) []string {
+
+	var out []string
+	for _, v := range strings.Split(in, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func readPasswordFile(fileName string) (string, error) {
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return "", fmt.Errorf("read password file: %w", err)
+	}
+	// password files usually end with new line
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
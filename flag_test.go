@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -101,6 +102,114 @@ func TestParseFlags(t *testing.T) {
 	})
 }
 
+func TestParseFlags_password(t *testing.T) {
+
+	t.Run("given password env var is set then password is set", func(t *testing.T) {
+
+		setInput(t, []string{"flag"}, map[string]string{"CERTINFO_PASSWORD": "secret"})
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, "secret", flags.Password)
+	})
+
+	t.Run("given password file is set then password is read from the file", func(t *testing.T) {
+
+		passwordFile := filepath.Join(t.TempDir(), "password")
+		require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0600))
+		setInput(t, []string{"flag", "-password-file", passwordFile}, nil)
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, "secret", flags.Password)
+	})
+
+	t.Run("given both password and password file are set then error is returned", func(t *testing.T) {
+
+		passwordFile := filepath.Join(t.TempDir(), "password")
+		require.NoError(t, os.WriteFile(passwordFile, []byte("secret"), 0600))
+		setInput(t, []string{"flag", "-password", "secret", "-password-file", passwordFile}, nil)
+
+		_, err := ParseFlags()
+		require.Error(t, err)
+	})
+}
+
+func TestParseFlags_resolve(t *testing.T) {
+
+	t.Run("given resolve flags and env var then all overrides are set", func(t *testing.T) {
+
+		setInput(t, []string{"flag", "-resolve", "api.example.com:443:10.0.0.5", "-resolve", "example.com:8443:[2001:db8::1]"},
+			map[string]string{"CERTINFO_RESOLVE": "db.example.com:5432:10.0.0.6"})
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{
+			"api.example.com:443": "10.0.0.5",
+			"example.com:8443":    "2001:db8::1",
+			"db.example.com:5432": "10.0.0.6",
+		}, flags.Resolve)
+	})
+
+	t.Run("given invalid resolve flag then error is returned", func(t *testing.T) {
+
+		setInput(t, []string{"flag", "-resolve", "api.example.com:10.0.0.5"}, nil)
+
+		_, err := ParseFlags()
+		assert.ErrorContains(t, err, "invalid resolve api.example.com:10.0.0.5")
+	})
+}
+
+func TestParseFlags_alpn(t *testing.T) {
+
+	t.Run("given alpn flag is not set then alpn is not offered", func(t *testing.T) {
+
+		setInput(t, []string{"flag"}, nil)
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Empty(t, flags.NetworkOptions().NextProtos)
+	})
+
+	t.Run("given alpn flag then protocols are offered", func(t *testing.T) {
+
+		setInput(t, []string{"flag", "-alpn", "h2, http/1.1"}, nil)
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"h2", "http/1.1"}, flags.NetworkOptions().NextProtos)
+	})
+}
+
+func TestParseFlags_revocation(t *testing.T) {
+
+	t.Run("given revocation flag is not set then revocation is not checked", func(t *testing.T) {
+
+		setInput(t, []string{"flag"}, nil)
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, "none", flags.Revocation)
+	})
+
+	t.Run("given ocsp revocation flag then ocsp revocation is set", func(t *testing.T) {
+
+		setInput(t, []string{"flag", "-revocation", "ocsp"}, nil)
+
+		flags, err := ParseFlags()
+		require.NoError(t, err)
+		assert.Equal(t, "ocsp", flags.Revocation)
+	})
+
+	t.Run("given unsupported revocation flag then error is returned", func(t *testing.T) {
+
+		setInput(t, []string{"flag", "-revocation", "crl"}, nil)
+
+		_, err := ParseFlags()
+		assert.EqualError(t, err, "invalid revocation crl: expected none or ocsp")
+	})
+}
+
 // --- helper functions ---
 
 func setInput(t *testing.T, args []string, env map[string]string) {
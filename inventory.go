@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"time"
+)
+
+// TargetEntry is single entry in targets inventory file
+type TargetEntry struct {
+	// Address is network address (same format as argument), file or directory path
+	Address  string        `yaml:"address"`
+	SNI      string        `yaml:"sni"`
+	Insecure *bool         `yaml:"insecure"`
+	Protocol string        `yaml:"protocol"`
+	Timeout  time.Duration `yaml:"timeout"`
+	// Hostnames are expected hostnames verified against leaf certificate
+	Hostnames []string          `yaml:"hostnames"`
+	Labels    map[string]string `yaml:"labels"`
+}
+
+// target returns network settings of the entry, address is not parsed
+func (e TargetEntry) target() target {
+	return target{serverName: e.SNI, insecure: e.Insecure, protocol: e.Protocol, timeout: e.Timeout}
+}
+
+// inventory is targets file, YAML or JSON
+type inventory struct {
+	Targets []TargetEntry `yaml:"targets"`
+}
+
+func readTargetsFile(fileName string) ([]TargetEntry, error) {
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("read targets file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(b))
+	// fail on typos, otherwise misspelled setting is silently ignored
+	decoder.KnownFields(true)
+	var i inventory
+	if err := decoder.Decode(&i); err != nil {
+		return nil, fmt.Errorf("parse targets file %s: %w", fileName, err)
+	}
+	for n, entry := range i.Targets {
+		if entry.Address == "" {
+			return nil, fmt.Errorf("parse targets file %s: target %d: missing address", fileName, n+1)
+		}
+	}
+	return i.Targets, nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_readTargetsFile(t *testing.T) {
+
+	t.Run("given yaml targets file then targets are loaded", func(t *testing.T) {
+
+		targetsFile := writeTargetsFile(t, "targets.yaml", `
+targets:
+  - address: 10.0.0.5:443
+    sni: api.example.com
+    insecure: true
+    timeout: 10s
+    hostnames: [api.example.com, www.example.com]
+    labels:
+      owner: team-a
+      env: prod
+  - address: mail.example.com:25
+    protocol: smtp
+  - address: /etc/ssl/certs
+`)
+		targets, err := readTargetsFile(targetsFile)
+		require.NoError(t, err)
+		require.Equal(t, 3, len(targets))
+
+		assert.Equal(t, "10.0.0.5:443", targets[0].Address)
+		assert.Equal(t, "api.example.com", targets[0].SNI)
+		require.NotNil(t, targets[0].Insecure)
+		assert.True(t, *targets[0].Insecure)
+		assert.Equal(t, 10*time.Second, targets[0].Timeout)
+		assert.Equal(t, []string{"api.example.com", "www.example.com"}, targets[0].Hostnames)
+		assert.Equal(t, map[string]string{"owner": "team-a", "env": "prod"}, targets[0].Labels)
+
+		assert.Equal(t, "smtp", targets[1].Protocol)
+		assert.Nil(t, targets[1].Insecure)
+		assert.Equal(t, "/etc/ssl/certs", targets[2].Address)
+	})
+
+	t.Run("given json targets file then targets are loaded", func(t *testing.T) {
+
+		targetsFile := writeTargetsFile(t, "targets.json", `{"targets": [{"address": "db.example.com:5432", "protocol": "postgres", "timeout": "2s", "labels": {"env": "dev"}}]}`)
+		targets, err := readTargetsFile(targetsFile)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(targets))
+		assert.Equal(t, "db.example.com:5432", targets[0].Address)
+		assert.Equal(t, "postgres", targets[0].Protocol)
+		assert.Equal(t, 2*time.Second, targets[0].Timeout)
+		assert.Equal(t, map[string]string{"env": "dev"}, targets[0].Labels)
+	})
+
+	t.Run("given unknown field then error is returned", func(t *testing.T) {
+
+		targetsFile := writeTargetsFile(t, "targets.yaml", "targets:\n  - address: example.com\n    snii: api.example.com\n")
+		_, err := readTargetsFile(targetsFile)
+		assert.ErrorContains(t, err, "field snii not found")
+	})
+
+	t.Run("given target without address then error is returned", func(t *testing.T) {
+
+		targetsFile := writeTargetsFile(t, "targets.yaml", "targets:\n  - sni: api.example.com\n")
+		_, err := readTargetsFile(targetsFile)
+		assert.ErrorContains(t, err, "target 1: missing address")
+	})
+}
+
+func Test_loadInput(t *testing.T) {
+
+	t.Run("given targets file entry then labels and expected hostnames are set on locations", func(t *testing.T) {
+
+		entry := TargetEntry{
+			Address:   "pkg/cert/testdata/bundle.pem",
+			Hostnames: []string{"example.com"},
+			Labels:    map[string]string{"env": "prod"},
+		}
+		locations := loadInput(t.Context(), input{path: entry.Address, entry: entry}, Flags{}, newPool(PoolOptions{}))
+		require.Equal(t, 1, len(locations))
+		assert.Equal(t, map[string]string{"env": "prod"}, locations[0].Labels)
+		assert.Equal(t, []string{"example.com"}, locations[0].ExpectedHostnames)
+	})
+}
+
+func writeTargetsFile(t *testing.T, name, content string) string {
+
+	targetsFile := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(targetsFile, []byte(content), 0600))
+	return targetsFile
+}
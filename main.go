@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/pete911/certinfo/pkg/cert"
 	"github.com/pete911/certinfo/pkg/print"
+	"io/fs"
 	"log/slog"
+	"net"
 	"os"
-	"strconv"
+	"os/signal"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 )
 
 var Version = "dev"
@@ -27,7 +35,33 @@ func main() {
 		os.Exit(0)
 	}
 
-	certificatesFiles := LoadCertificatesLocations(flags)
+	ctx, cancel := runContext(flags.Deadline)
+	defer cancel()
+
+	// sorting and grouping need all locations, otherwise locations are printed as soon as they are loaded
+	if flags.SortExpiry || (flags.Expiry && flags.GroupBy != "") {
+		certificatesFiles := filterLocations(flags, LoadCertificatesLocations(ctx, flags))
+		if flags.SortExpiry {
+			certificatesFiles = certificatesFiles.SortByExpiry()
+		}
+		printLocations(flags, certificatesFiles)
+		printSummary(flags, certificatesFiles)
+		return
+	}
+	var locations cert.CertificateLocations
+	StreamCertificatesLocations(ctx, flags, flags.Ordered, func(location cert.CertificateLocation) {
+		filtered := filterLocations(flags, cert.CertificateLocations{location})
+		printLocations(flags, filtered)
+		// locations are kept only for the summary
+		if flags.PQ {
+			locations = append(locations, filtered...)
+		}
+	})
+	printSummary(flags, locations)
+}
+
+func filterLocations(flags Flags, certificatesFiles cert.CertificateLocations) cert.CertificateLocations {
+
 	if flags.NoExpired {
 		certificatesFiles = certificatesFiles.RemoveExpired()
 	}
@@ -40,18 +74,64 @@ func main() {
 	if flags.IssuerLike != "" {
 		certificatesFiles = certificatesFiles.IssuerLike(flags.IssuerLike)
 	}
-	if flags.SortExpiry {
-		certificatesFiles = certificatesFiles.SortByExpiry()
+	return certificatesFiles
+}
+
+func printLocations(flags Flags, certificatesFiles cert.CertificateLocations) {
+
+	if flags.Scan {
+		print.Scan(certificatesFiles)
+		return
 	}
 	if flags.Expiry {
+		if flags.GroupBy != "" {
+			print.ExpiryByLabel(certificatesFiles, flags.GroupBy)
+			return
+		}
 		print.Expiry(certificatesFiles)
 		return
 	}
 	if flags.PemOnly {
-		print.Pem(certificatesFiles, flags.Chains)
+		print.Pem(certificatesFiles, flags.Trust, flags.Chains)
 		return
 	}
-	print.Locations(certificatesFiles, flags.Chains, flags.Pem, flags.Extensions, flags.Signature)
+	print.Locations(certificatesFiles, flags.Trust, flags.Chains, flags.Pem, flags.Extensions, flags.Signature)
+}
+
+// printSummary prints summary of all locations after the locations are printed
+func printSummary(flags Flags, certificatesFiles cert.CertificateLocations) {
+
+	if flags.PQ {
+		print.KeyExchangeSummary(certificatesFiles)
+	}
+}
+
+// runContext returns context that is cancelled on interrupt (second interrupt terminates the program) or when the
+// deadline is exceeded
+func runContext(deadline time.Duration) (context.Context, context.CancelFunc) {
+
+	ctx, cancelCause := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		if sig, ok := <-signals; ok {
+			signal.Stop(signals)
+			cancelCause(fmt.Errorf("received %s", sig))
+		}
+	}()
+	cancel := func() {
+		signal.Stop(signals)
+		cancelCause(context.Canceled)
+	}
+
+	if deadline <= 0 {
+		return ctx, cancel
+	}
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, deadline, errors.New("run deadline exceeded"))
+	return ctx, func() {
+		cancelTimeout()
+		cancel()
+	}
 }
 
 func setLogger(verbose bool) {
@@ -62,71 +142,200 @@ func setLogger(verbose bool) {
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 }
 
-func LoadCertificatesLocations(flags Flags) cert.CertificateLocations {
+// LoadCertificatesLocations loads all locations in argument order
+func LoadCertificatesLocations(ctx context.Context, flags Flags) cert.CertificateLocations {
 
 	var certificateLocations cert.CertificateLocations
-	if len(flags.Args) > 0 {
-		certificateLocations = append(certificateLocations, loadFromArgs(flags.Args, flags.ServerName, flags.Insecure)...)
+	StreamCertificatesLocations(ctx, flags, true, func(location cert.CertificateLocation) {
+		certificateLocations = append(certificateLocations, location)
+	})
+	return certificateLocations
+}
+
+// StreamCertificatesLocations loads certificates from arguments, targets file and stdin and calls emit for every location
+// as soon as it is loaded, in ordered mode locations are emitted in argument order. Emit is never called concurrently.
+func StreamCertificatesLocations(ctx context.Context, flags Flags, ordered bool, emit func(cert.CertificateLocation)) {
+
+	stdin := isStdin()
+	if len(flags.Args) == 0 && len(flags.Targets) == 0 && !stdin {
+		// no stdin and no args
+		flags.Usage()
+		os.Exit(0)
+	}
+
+	loadFromArgs(ctx, flags, newEmitter(ordered, emit))
+	if stdin {
+		for _, location := range cert.LoadCertificatesFromStdin(ctx, flags.Password) {
+			logLoadError(location)
+			emit(location)
+		}
+	}
+}
+
+// input is a single argument or targets file entry (or file found in directory) to load certificates from
+type input struct {
+	path string
+	// files found in directories are skipped quietly if they do not contain any certificate
+	fromDirectory bool
+	err           error
+	// settings from targets file, empty for arguments
+	entry TargetEntry
+}
+
+// loadFromArgs loads certificates from arguments and targets file entries
+func loadFromArgs(ctx context.Context, flags Flags, e *emitter) {
+
+	var inputs []input
+	for _, arg := range flags.Args {
+		inputs = append(inputs, input{path: arg})
+	}
+	for _, entry := range flags.Targets {
+		inputs = append(inputs, input{path: entry.Address, entry: entry})
+	}
+	inputs = expandDirectories(inputs, flags.WalkOptions)
+	p := newPool(flags.Pool)
+	p.run(len(inputs), func(i int) {
+		var locations cert.CertificateLocations
+		for _, location := range loadInput(ctx, inputs[i], flags, p) {
+			if inputs[i].fromDirectory && !hasCertificates(location) {
+				slog.Debug(fmt.Sprintf("skipping %s: no certificates found", location.Path))
+				continue
+			}
+			logLoadError(location)
+			locations = append(locations, location)
+		}
+		e.done(i, locations)
+	})
+}
+
+// expandDirectories replaces directory inputs with files found in the directory
+func expandDirectories(inputs []input, walkOptions WalkOptions) []input {
+
+	var out []input
+	for _, in := range inputs {
+		if !isDirectory(in.path) {
+			out = append(out, in)
+			continue
+		}
+		files, err := findFiles(in.path, walkOptions)
+		if err != nil {
+			in.err = err
+			out = append(out, in)
+			continue
+		}
+		for _, file := range files {
+			out = append(out, input{path: file, fromDirectory: true, entry: in.entry})
+		}
 	}
+	return out
+}
 
-	if isStdin() {
-		certificateLocations = append(certificateLocations, cert.LoadCertificateFromStdin())
+func loadInput(ctx context.Context, in input, flags Flags, p *pool) cert.CertificateLocations {
+
+	locations := loadInputLocations(ctx, in, flags, p)
+	for i := range locations {
+		locations[i].Labels = in.entry.Labels
+		locations[i].ExpectedHostnames = in.entry.Hostnames
+		if flags.Revocation == cert.RevocationOCSP {
+			locations[i] = locations[i].CheckOCSP(ctx, flags.NetworkOptions())
+		}
 	}
+	return locations
+}
+
+func loadInputLocations(ctx context.Context, in input, flags Flags, p *pool) cert.CertificateLocations {
 
-	if len(certificateLocations) > 0 {
-		return certificateLocations
+	if in.err != nil {
+		return cert.CertificateLocations{{Path: in.path, Error: in.err}}
+	}
+	if in.fromDirectory || isFile(in.path) {
+		return cert.LoadCertificatesFromFile(ctx, in.path, flags.Password)
 	}
 
-	// no stdin and no args
-	flags.Usage()
-	os.Exit(0)
-	return nil
+	t, err := parseTarget(in.path, flags.DefaultPort)
+	if err != nil {
+		err = fmt.Errorf("neither existing file nor network address: %w", err)
+		return cert.CertificateLocations{{Path: in.path, Error: err}}
+	}
+	// targets file entry settings are applied first, so they can be overridden by address options
+	options := t.networkOptions(in.entry.target().networkOptions(flags.NetworkOptions()))
+	if ip, ok := flags.Resolve[strings.ToLower(t.addr)]; ok {
+		options.IP = ip
+	}
+	// scan and key exchange probes connect in the dial slot of the target, so every probe is rate limited as well
+	options.ProbeWait = p.limiter.wait
+	if flags.AllIPs && options.IP == "" && net.ParseIP(t.host()) == nil {
+		return loadAllIPs(ctx, t, options, p)
+	}
+	location := p.dial(ctx, t.host(), func() cert.CertificateLocation {
+		return cert.LoadCertificatesFromNetwork(ctx, t.addr, options)
+	})
+	return cert.CertificateLocations{location}
 }
 
-func loadFromArgs(args []string, serverName string, insecure bool) cert.CertificateLocations {
+// loadAllIPs resolves the target host and loads certificates from every IP, IPs serving different certificate than
+// other IPs are marked
+func loadAllIPs(ctx context.Context, t target, options cert.NetworkOptions, p *pool) cert.CertificateLocations {
 
-	out := make(chan cert.CertificateLocation)
-	go func() {
-		var wg sync.WaitGroup
-		for _, arg := range args {
-			wg.Add(1)
-			go func() {
-				defer wg.Done()
-				if isTCPNetworkAddress(arg) {
-					out <- cert.LoadCertificatesFromNetwork(arg, serverName, insecure)
-					return
-				}
-				out <- cert.LoadCertificatesFromFile(arg)
-			}()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", t.host())
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("%w: %w", cert.ErrCancelled, context.Cause(ctx))
 		}
-		wg.Wait()
-		close(out)
-	}()
+		return cert.CertificateLocations{{Path: t.addr, Error: fmt.Errorf("resolve: %w", err)}}
+	}
+	// IPv4 addresses first
+	slices.SortFunc(ips, func(a, b net.IP) int { return bytes.Compare(a.To16(), b.To16()) })
 
-	// load certificates from the channel
-	certsByArgs := make(map[string]cert.CertificateLocation)
-	for location := range out {
-		certsByArgs[location.Path] = location
+	locations := make(cert.CertificateLocations, len(ips))
+	var wg sync.WaitGroup
+	for i, ip := range ips {
+		ipOptions := options
+		ipOptions.IP = ip.String()
+		wg.Go(func() {
+			locations[i] = p.dial(ctx, t.host(), func() cert.CertificateLocation {
+				return cert.LoadCertificatesFromNetwork(ctx, t.addr, ipOptions)
+			})
+		})
 	}
+	wg.Wait()
+	return locations.MarkCertificateMismatch()
+}
+
+// logLoadError logs error of loaded location, loaders do not log errors, so files found in directories without
+// certificates are skipped quietly and failed attempts that are retried are logged only in debug by the pool. Cancelled
+// location did not fail, so it is logged as warning.
+func logLoadError(location cert.CertificateLocation) {
 
-	// sort certificates by input arguments
-	var certsSortedByArgs cert.CertificateLocations
-	for _, arg := range args {
-		certsSortedByArgs = append(certsSortedByArgs, certsByArgs[arg])
+	switch {
+	case location.Error == nil:
+		return
+	case location.Cancelled():
+		slog.Warn(fmt.Sprintf("load certificate from %s: %v", location.Name(), location.Error))
+	default:
+		slog.Error(fmt.Sprintf("load certificate from %s: %v", location.Name(), location.Error))
 	}
-	return certsSortedByArgs
 }
 
-func isTCPNetworkAddress(arg string) bool {
+func hasCertificates(location cert.CertificateLocation) bool {
 
-	parts := strings.Split(arg, ":")
-	if len(parts) != 2 {
+	if location.Error != nil {
 		return false
 	}
-	if _, err := strconv.Atoi(parts[1]); err != nil {
-		return false
+	for _, certificate := range location.Certificates {
+		if certificate.Error() == nil {
+			return true
+		}
 	}
-	return true
+	return false
+}
+
+// isFile checks if the argument is a file, files that cannot be accessed (e.g. permissions) are files as well, so the
+// error is reported when loading certificates
+func isFile(arg string) bool {
+
+	_, err := os.Stat(arg)
+	return !errors.Is(err, fs.ErrNotExist)
 }
 
 func isStdin() bool {
@@ -307,6 +307,24 @@ func ToExtendedKeyUsage(in []byte) ([]string, error) {
 	return extKeyUsages, nil
 }
 
+// tlsFeatureStatusRequest is TLS status_request extension, certificate with this feature requires OCSP staple
+// (must-staple)
+const tlsFeatureStatusRequest = 5
+
+var tlsFeatures = map[int]string{
+	tlsFeatureStatusRequest: "status_request",
+	17:                      "status_request_v2",
+}
+
+// Features ::= SEQUENCE OF INTEGER
+func ToTLSFeature(in []byte) ([]int, error) {
+	var out []int
+	if _, err := asn1.Unmarshal(in, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // BasicConstraints ::= SEQUENCE {
 // cA                      BOOLEAN DEFAULT FALSE,
 // pathLenConstraint       INTEGER (0..MAX) OPTIONAL }
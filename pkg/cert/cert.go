@@ -15,7 +15,11 @@ import (
 	"time"
 )
 
-const certificateBlockType = "CERTIFICATE"
+const (
+	certificateBlockType = "CERTIFICATE"
+	// DER encoded certificate starts with constructed ASN.1 SEQUENCE tag
+	derSequenceTag = 0x30
+)
 
 var (
 	// order is important!
@@ -103,9 +107,13 @@ func (c Certificates) SortByExpiry() Certificates {
 
 type Certificate struct {
 	// position of certificate in the chain, starts with 1
-	position        int
+	position int
+	// alias (friendly name) of the keystore entry, only applicable for keystores
+	alias           string
 	x509Certificate *x509.Certificate
 	err             error
+	// revocation is set only if revocation was checked
+	revocation *Revocation
 }
 
 func FromX509Certificates(cs []*x509.Certificate) Certificates {
@@ -118,19 +126,29 @@ func FromX509Certificates(cs []*x509.Certificate) Certificates {
 }
 
 // FromBytes converts raw certificate bytes to certificate, if the supplied data is cert bundle (or chain)
-// all the certificates will be returned
+// all the certificates will be returned. Data can be either PEM or DER encoded.
 func FromBytes(data []byte) (Certificates, error) {
 
+	if isDER(data) {
+		if isPKCS7(data) {
+			return fromPKCS7(1, data), nil
+		}
+		return fromDER(data)
+	}
+	return fromPEM(bytes.TrimSpace(data))
+}
+
+func fromPEM(data []byte) (Certificates, error) {
+
 	var block *pem.Block
 	var certificates Certificates
-	var i int
 	for {
-		i++
 		block, data = pem.Decode(data)
 		if block == nil {
 			return nil, errors.New("cannot find any PEM block")
 		}
-		certificates = append(certificates, fromPemBlock(i, block))
+		// PKCS7 block can contain multiple certificates, position is the position of the next certificate
+		certificates = append(certificates, fromPemBlock(len(certificates)+1, block)...)
 		if len(data) == 0 {
 			break
 		}
@@ -138,12 +156,52 @@ func FromBytes(data []byte) (Certificates, error) {
 	return certificates, nil
 }
 
-func fromPemBlock(position int, block *pem.Block) Certificate {
+// fromDER parses single DER certificate or concatenated sequence of DER certificates, whitespace after the last
+// certificate (e.g. new line added by editor) is ignored
+func fromDER(data []byte) (Certificates, error) {
 
-	if block.Type != certificateBlockType {
-		return Certificate{position: position, err: fmt.Errorf("cannot parse %s block", block.Type)}
+	var certificates Certificates
+	var i int
+	// only the rest after complete certificate is checked, DER certificate itself can end with whitespace bytes
+	for len(bytes.TrimSpace(data)) != 0 {
+		i++
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(data, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse DER block at position %d: %w", i, err)
+		}
+		certificates = append(certificates, fromDERBlock(i, raw.FullBytes))
+		data = rest
+	}
+	return certificates, nil
+}
+
+// isDER checks if the data starts with ASN.1 sequence, PEM data is text and cannot be decoded as ASN.1
+func isDER(data []byte) bool {
+
+	if len(data) == 0 || data[0] != derSequenceTag {
+		return false
 	}
-	certificate, err := x509.ParseCertificate(block.Bytes)
+	var raw asn1.RawValue
+	_, err := asn1.Unmarshal(data, &raw)
+	return err == nil
+}
+
+func fromPemBlock(position int, block *pem.Block) Certificates {
+
+	switch block.Type {
+	case certificateBlockType:
+		return Certificates{fromDERBlock(position, block.Bytes)}
+	case pkcs7BlockType, cmsBlockType:
+		return fromPKCS7(position, block.Bytes)
+	default:
+		return Certificates{{position: position, err: fmt.Errorf("cannot parse %s block", block.Type)}}
+	}
+}
+
+func fromDERBlock(position int, der []byte) Certificate {
+
+	certificate, err := x509.ParseCertificate(der)
 	if err != nil {
 		return Certificate{position: position, err: err}
 	}
@@ -189,6 +247,16 @@ func (c Certificate) Error() error {
 	return nil
 }
 
+func (c Certificate) Alias() string {
+	return c.alias
+}
+
+// Revocation returns online revocation check of the certificate, nil if the certificate was not checked (e.g. it is
+// self-signed or revocation check was not requested)
+func (c Certificate) Revocation() *Revocation {
+	return c.revocation
+}
+
 func (c Certificate) DNSNames() []string {
 	if c.x509Certificate == nil {
 		// this is called with -expiry flag as well, this call does not check if there is cert error
@@ -278,6 +346,26 @@ func (c Certificate) ExtKeyUsage() []string {
 	return extendedKeyUsageString
 }
 
+// MustStaple checks if the certificate has TLS feature extension with status_request, server has to staple OCSP
+// response for this certificate
+func (c Certificate) MustStaple() bool {
+
+	if c.x509Certificate == nil {
+		return false
+	}
+	for _, extension := range c.x509Certificate.Extensions {
+		if extension.Id.String() != "1.3.6.1.5.5.7.1.24" {
+			continue
+		}
+		features, err := ToTLSFeature(extension.Value)
+		if err != nil {
+			return false
+		}
+		return slices.Contains(features, tlsFeatureStatusRequest)
+	}
+	return false
+}
+
 func (c Certificate) Type() string {
 	if c.x509Certificate.AuthorityKeyId == nil || bytes.Equal(c.x509Certificate.AuthorityKeyId, c.x509Certificate.SubjectKeyId) {
 		return "root"
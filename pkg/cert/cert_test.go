@@ -23,6 +23,55 @@ func TestFromBytes(t *testing.T) {
 		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", certificates[0].SubjectString())
 		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", certificates[1].SubjectString())
 	})
+
+	t.Run("given valid DER certificate, then certificate is loaded", func(t *testing.T) {
+		certificates, err := FromBytes(loadTestFile(t, "cert.der"))
+		require.NoError(t, err)
+		require.Equal(t, 1, len(certificates))
+		assert.Equal(t, 1, certificates[0].position)
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", certificates[0].SubjectString())
+		assert.Nil(t, certificates[0].err)
+	})
+
+	t.Run("given concatenated DER certificates, then all certificates are loaded", func(t *testing.T) {
+		certificates, err := FromBytes(loadTestFile(t, "bundle.der"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(certificates))
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", certificates[0].SubjectString())
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", certificates[1].SubjectString())
+		assert.Equal(t, 2, certificates[1].position)
+	})
+
+	t.Run("given DER certificates followed by new line, then all certificates are loaded", func(t *testing.T) {
+		certificates, err := FromBytes(append(loadTestFile(t, "bundle.der"), "\r\n"...))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(certificates))
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", certificates[1].SubjectString())
+	})
+
+	t.Run("given PEM PKCS7 bundle, then all certificates are loaded", func(t *testing.T) {
+		certificates := loadTestCertificates(t, "bundle.p7b")
+		require.Equal(t, 2, len(certificates))
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", certificates[0].SubjectString())
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", certificates[1].SubjectString())
+	})
+
+	t.Run("given DER PKCS7 bundle, then all certificates are loaded", func(t *testing.T) {
+		certificates, err := FromBytes(loadTestFile(t, "bundle.p7c"))
+		require.NoError(t, err)
+		require.Equal(t, 2, len(certificates))
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", certificates[0].SubjectString())
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", certificates[1].SubjectString())
+	})
+
+	t.Run("given PEM PKCS7 bundle followed by certificate, then certificates have correct positions", func(t *testing.T) {
+		certificates := loadTestCertificates(t, "bundle.p7b", "cert.pem")
+		require.Equal(t, 3, len(certificates))
+		assert.Equal(t, 1, certificates[0].position)
+		assert.Equal(t, 2, certificates[1].position)
+		assert.Equal(t, 3, certificates[2].position)
+		assert.Nil(t, certificates[2].err)
+	})
 }
 
 func TestCertificates_RemoveDuplicates(t *testing.T) {
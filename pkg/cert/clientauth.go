@@ -0,0 +1,103 @@
+package cert
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ClientAuth is client certificate request sent by the server during TLS handshake
+type ClientAuth struct {
+	// AcceptableCAs are distinguished names of CAs the server accepts client certificates from, empty if the server
+	// does not restrict CAs
+	AcceptableCAs []string
+	// CertificateSent is set if client certificate was sent to the server
+	CertificateSent bool
+}
+
+// LoadClientCertificate loads client certificate and private key for mutual TLS from PEM files or from PKCS#12 file,
+// key file is optional if the certificate file contains private key as well and password is only used for PKCS#12
+func LoadClientCertificate(certFile, keyFile, password string) (tls.Certificate, error) {
+
+	certData, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("read client certificate: %w", err)
+	}
+	if isPKCS12(certData) {
+		certificate, err := clientCertificateFromPKCS12(certData, password)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("client certificate %s: %w", certFile, err)
+		}
+		return certificate, nil
+	}
+
+	keyData := certData
+	if keyFile != "" {
+		if keyData, err = os.ReadFile(keyFile); err != nil {
+			return tls.Certificate{}, fmt.Errorf("read client key: %w", err)
+		}
+	}
+	certificate, err := tls.X509KeyPair(certData, keyData)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("client certificate %s: %w", certFile, err)
+	}
+	return certificate, nil
+}
+
+// clientCertificateFromPKCS12 returns leaf certificate with the rest of the certificates as chain and private key that
+// matches the leaf certificate
+func clientCertificateFromPKCS12(data []byte, password string) (tls.Certificate, error) {
+
+	certificates, keys, err := decodePKCS12(data, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf := leafCertificate(certificates)
+	if leaf == nil {
+		return tls.Certificate{}, errors.New("no certificate found")
+	}
+
+	out := tls.Certificate{Certificate: [][]byte{leaf.Raw}, Leaf: leaf}
+	for _, certificate := range certificates {
+		if certificate.err == nil && certificate.x509Certificate != leaf {
+			out.Certificate = append(out.Certificate, certificate.x509Certificate.Raw)
+		}
+	}
+	for _, key := range keys {
+		if toPrivateKey(key, leaf).MatchesLeaf {
+			out.PrivateKey = key.(crypto.Signer)
+			return out, nil
+		}
+	}
+	return tls.Certificate{}, errors.New("no private key matching leaf certificate found")
+}
+
+// clientCertificate returns certificate sent in response to the server certificate request, the certificate is sent
+// only if it is set and the server accepts it, otherwise empty certificate (no certificate) is sent
+func clientCertificate(request *tls.CertificateRequestInfo, certificate *tls.Certificate) (*tls.Certificate, *ClientAuth) {
+
+	clientAuth := &ClientAuth{AcceptableCAs: distinguishedNames(request.AcceptableCAs)}
+	if certificate == nil || request.SupportsCertificate(certificate) != nil {
+		return &tls.Certificate{}, clientAuth
+	}
+	clientAuth.CertificateSent = true
+	return certificate, clientAuth
+}
+
+func distinguishedNames(names [][]byte) []string {
+
+	var out []string
+	for _, name := range names {
+		var rdn pkix.RDNSequence
+		if _, err := asn1.Unmarshal(name, &rdn); err != nil {
+			out = append(out, fmt.Sprintf("ERROR: asn1 unmarshal distinguished name: %v", err))
+			continue
+		}
+		out = append(out, rdn.String())
+	}
+	return out
+}
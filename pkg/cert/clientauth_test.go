@@ -0,0 +1,135 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_clientAuth(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCertificate := testClientCertificate(t, pki)
+
+	t.Run("given server requests client certificate and no certificate is set then acceptable CAs are reported", func(t *testing.T) {
+		addr := startClientAuthServer(t, pki.intermediate, tls.VersionTLS13)
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.ClientAuth)
+		assert.Equal(t, []string{"CN=certinfo test intermediate"}, location.ClientAuth.AcceptableCAs)
+		assert.False(t, location.ClientAuth.CertificateSent)
+		require.Equal(t, 1, len(location.Certificates))
+	})
+
+	t.Run("given tls 1.2 server rejects missing client certificate then acceptable CAs are reported with error", func(t *testing.T) {
+		addr := startClientAuthServer(t, pki.intermediate, tls.VersionTLS12)
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.Error(t, location.Error)
+		require.NotNil(t, location.ClientAuth)
+		assert.Equal(t, []string{"CN=certinfo test intermediate"}, location.ClientAuth.AcceptableCAs)
+	})
+
+	t.Run("given client certificate then it is sent to the server", func(t *testing.T) {
+		addr := startClientAuthServer(t, pki.intermediate, tls.VersionTLS12)
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, ClientCertificate: &clientCertificate})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.ClientAuth)
+		assert.True(t, location.ClientAuth.CertificateSent)
+	})
+
+	t.Run("given server does not request client certificate then client auth is not set", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, ClientCertificate: &clientCertificate})
+		require.NoError(t, location.Error)
+		assert.Nil(t, location.ClientAuth)
+	})
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	pki := newTestPKI(t)
+	clientCertificate := testClientCertificate(t, pki)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: clientCertificate.Certificate[0]})
+	keyDER, err := x509.MarshalPKCS8PrivateKey(clientCertificate.PrivateKey)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	t.Run("given pem certificate and key files then client certificate is loaded", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "client.crt"), certPEM, 0600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "client.key"), keyPEM, 0600))
+
+		certificate, err := LoadClientCertificate(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"), "")
+		require.NoError(t, err)
+		assert.Equal(t, clientCertificate.Certificate, certificate.Certificate)
+	})
+
+	t.Run("given pem file with certificate and key then client certificate is loaded", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "client.pem")
+		require.NoError(t, os.WriteFile(file, append(certPEM, keyPEM...), 0600))
+
+		certificate, err := LoadClientCertificate(file, "", "")
+		require.NoError(t, err)
+		assert.Equal(t, clientCertificate.Certificate, certificate.Certificate)
+	})
+
+	t.Run("given pkcs12 file then client certificate with matching key is loaded", func(t *testing.T) {
+		certificate, err := LoadClientCertificate(filepath.Join("testdata", "keystore.p12"), "", "test")
+		require.NoError(t, err)
+		require.NotNil(t, certificate.Leaf)
+		assert.Equal(t, certificate.Leaf.Raw, certificate.Certificate[0])
+		assert.NotNil(t, certificate.PrivateKey)
+	})
+
+	t.Run("given pkcs12 file and incorrect password then error is returned", func(t *testing.T) {
+		_, err := LoadClientCertificate(filepath.Join("testdata", "keystore.p12"), "", "incorrect")
+		assert.Error(t, err)
+	})
+
+	t.Run("given pem certificate without key then error is returned", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "client.crt")
+		require.NoError(t, os.WriteFile(file, certPEM, 0600))
+
+		_, err := LoadClientCertificate(file, "", "")
+		assert.ErrorContains(t, err, "client certificate "+file)
+	})
+}
+
+// startClientAuthServer starts TLS server that requires client certificate issued by the CA
+func startClientAuthServer(t *testing.T, ca *x509.Certificate, version uint16) string {
+	tlsConfig := testServerTLSConfig(t)
+	tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	tlsConfig.ClientCAs = x509.NewCertPool()
+	tlsConfig.ClientCAs.AddCert(ca)
+	tlsConfig.MaxVersion = version
+	return startTestServer(t, func(conn net.Conn) {
+		tls.Server(conn, tlsConfig).Handshake()
+	})
+}
+
+// testClientCertificate returns client certificate issued by the test intermediate
+func testClientCertificate(t *testing.T, pki testPKI) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	certificate := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "certinfo client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, pki.intermediate, key, pki.intermediateKey)
+	return tls.Certificate{Certificate: [][]byte{certificate.Raw}, PrivateKey: key, Leaf: certificate}
+}
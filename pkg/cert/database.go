@@ -0,0 +1,138 @@
+package cert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	// postgresSSLRequestCode is sent instead of protocol version in SSLRequest message
+	postgresSSLRequestCode = 80877103
+
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlMaxPacketSize          = 1<<24 - 1
+	mysqlCharsetUTF8MB4         = 45
+	mysqlErrorPacket            = 0xff
+)
+
+// PostgreSQL, SSLRequest message is sent before startup message and server responds with single byte
+func postgresStartTLS(conn net.Conn, _ string) error {
+
+	request := make([]byte, 8)
+	binary.BigEndian.PutUint32(request[0:4], 8)
+	binary.BigEndian.PutUint32(request[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response := make([]byte, 1)
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return fmt.Errorf("ssl request: %w", err)
+	}
+	switch response[0] {
+	case 'S':
+		return nil
+	case 'N':
+		return errors.New("server does not support SSL")
+	default:
+		return fmt.Errorf("ssl request: unexpected response %q", response[0])
+	}
+}
+
+// MySQL, server sends initial handshake packet with capability flags, client responds with SSLRequest packet (first
+// part of handshake response) and continues with TLS handshake
+func mysqlStartTLS(conn net.Conn, _ string) error {
+
+	sequence, payload, err := mysqlReadPacket(conn)
+	if err != nil {
+		return fmt.Errorf("initial handshake: %w", err)
+	}
+	capabilities, err := mysqlServerCapabilities(payload)
+	if err != nil {
+		return fmt.Errorf("initial handshake: %w", err)
+	}
+	if capabilities&mysqlClientSSL == 0 {
+		return errors.New("server does not support SSL")
+	}
+
+	request := make([]byte, 32)
+	binary.LittleEndian.PutUint32(request[0:4], mysqlClientSSL|mysqlClientProtocol41|mysqlClientSecureConnection)
+	binary.LittleEndian.PutUint32(request[4:8], mysqlMaxPacketSize)
+	request[8] = mysqlCharsetUTF8MB4
+	// remaining 23 bytes are reserved (zero)
+	return mysqlWritePacket(conn, sequence+1, request)
+}
+
+// mysqlServerCapabilities returns capability flags from initial handshake (protocol version 10) payload
+func mysqlServerCapabilities(payload []byte) (uint32, error) {
+
+	if len(payload) == 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if payload[0] == mysqlErrorPacket {
+		return 0, mysqlError(payload)
+	}
+	if payload[0] != 10 {
+		return 0, fmt.Errorf("unsupported protocol version %d", payload[0])
+	}
+
+	// protocol version, null terminated server version, connection id (4), auth plugin data (8), filler (1)
+	i := 1
+	for i < len(payload) && payload[i] != 0 {
+		i++
+	}
+	i += 1 + 4 + 8 + 1
+	if i+2 > len(payload) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	capabilities := uint32(binary.LittleEndian.Uint16(payload[i : i+2]))
+
+	// character set (1), status flags (2), upper capability flags (2)
+	i += 2 + 1 + 2
+	if i+2 <= len(payload) {
+		capabilities |= uint32(binary.LittleEndian.Uint16(payload[i:i+2])) << 16
+	}
+	return capabilities, nil
+}
+
+// mysqlError returns error from ERR packet, 0xff, error code (2) and message optionally prefixed with sql state
+func mysqlError(payload []byte) error {
+
+	if len(payload) < 3 {
+		return errors.New("server error")
+	}
+	code := binary.LittleEndian.Uint16(payload[1:3])
+	message := payload[3:]
+	if len(message) > 6 && message[0] == '#' {
+		message = message[6:]
+	}
+	return fmt.Errorf("server error %d: %s", code, message)
+}
+
+// mysqlReadPacket reads packet with 3 byte length and 1 byte sequence header
+func mysqlReadPacket(r io.Reader) (byte, []byte, error) {
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[3], payload, nil
+}
+
+func mysqlWritePacket(w io.Writer, sequence byte, payload []byte) error {
+
+	length := len(payload)
+	header := []byte{byte(length), byte(length >> 8), byte(length >> 16), sequence}
+	_, err := w.Write(append(header, payload...))
+	return err
+}
@@ -0,0 +1,87 @@
+package cert
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_database(t *testing.T) {
+	t.Run("given postgres server then certificates are loaded after ssl request", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			request := make([]byte, 8)
+			if _, err := io.ReadFull(conn, request); err != nil {
+				return
+			}
+			if binary.BigEndian.Uint32(request[4:]) != postgresSSLRequestCode {
+				return
+			}
+			conn.Write([]byte{'S'})
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "postgres", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, uint16(tls.VersionTLS13), location.TLSVersion)
+	})
+
+	t.Run("given postgres server without ssl then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			io.ReadFull(conn, make([]byte, 8))
+			conn.Write([]byte{'N'})
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "postgres", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "server does not support SSL")
+	})
+
+	t.Run("given mysql server then certificates are loaded after ssl request", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			mysqlWritePacket(conn, 0, mysqlInitialHandshake(mysqlClientSSL|mysqlClientProtocol41))
+			sequence, payload, err := mysqlReadPacket(conn)
+			if err != nil || sequence != 1 || binary.LittleEndian.Uint32(payload)&mysqlClientSSL == 0 {
+				return
+			}
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "mysql", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, uint16(tls.VersionTLS13), location.TLSVersion)
+	})
+
+	t.Run("given mysql server without ssl capability then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			mysqlWritePacket(conn, 0, mysqlInitialHandshake(mysqlClientProtocol41))
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "mysql", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "server does not support SSL")
+	})
+
+	t.Run("given mysql server error packet then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			mysqlWritePacket(conn, 0, append([]byte{0xff, 0x6a, 0x04}, "Host is not allowed to connect"...))
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "mysql", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "server error 1130: Host is not allowed to connect")
+	})
+}
+
+func mysqlInitialHandshake(capabilities uint32) []byte {
+	payload := []byte{10}
+	payload = append(payload, "8.0.36\x00"...)
+	payload = append(payload, 1, 0, 0, 0)        // connection id
+	payload = append(payload, "12345678\x00"...) // auth plugin data part 1 and filler
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(capabilities))
+	payload = append(payload, mysqlCharsetUTF8MB4, 2, 0) // character set and status
+	payload = binary.LittleEndian.AppendUint16(payload, uint16(capabilities>>16))
+	return payload
+}
@@ -38,7 +38,8 @@ var extensionsByOid = map[string]func(in []byte) (string, []string, error){
 	//"2.5.29.54": parseInhibitAnyPolicy,
 	//"2.5.29.46": parseFreshestCRL,
 	// private internet extensions
-	"1.3.6.1.5.5.7.1.1": parseAuthorityInformationAccess,
+	"1.3.6.1.5.5.7.1.1":  parseAuthorityInformationAccess,
+	"1.3.6.1.5.5.7.1.24": parseTLSFeature,
 	//"1.3.6.1.5.5.7.11": parseSubjectInformationAccess,
 	"1.3.6.1.4.1.11129.2.4.2": parseSignedCertificateTimestampList,
 }
@@ -190,6 +191,24 @@ func parseSignedCertificateTimestampList(in []byte) (string, []string, error) {
 	//return name, []string{formatHexArray(out)}, nil
 }
 
+// Features ::= SEQUENCE OF INTEGER
+func parseTLSFeature(in []byte) (string, []string, error) {
+	name := "TLS Feature"
+	out, err := ToTLSFeature(in)
+	if err != nil {
+		return name, nil, err
+	}
+	var features []string
+	for _, feature := range out {
+		if v, ok := tlsFeatures[feature]; ok {
+			features = append(features, fmt.Sprintf("%s (%d)", v, feature))
+			continue
+		}
+		features = append(features, fmt.Sprintf("%d", feature))
+	}
+	return name, features, nil
+}
+
 // BasicConstraints ::= SEQUENCE {
 // cA                      BOOLEAN DEFAULT FALSE,
 // pathLenConstraint       INTEGER (0..MAX) OPTIONAL }
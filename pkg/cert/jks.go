@@ -0,0 +1,207 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"unicode/utf16"
+)
+
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	jksPrivateKeyEntry  = 1
+	jksTrustedCertEntry = 2
+	jksSecretKeyEntry   = 3 // only JCEKS
+
+	// keystore integrity digest is SHA-1 of password, this string and the keystore content
+	jksDigestWhitener = "Mighty Aphrodite"
+)
+
+var errIncorrectStorePassword = errors.New("jks: keystore password was incorrect")
+
+// isJKS checks if the data is Java KeyStore (JKS or JCEKS)
+func isJKS(data []byte) bool {
+
+	if len(data) < 4 {
+		return false
+	}
+	magic := binary.BigEndian.Uint32(data)
+	return magic == jksMagic || magic == jceksMagic
+}
+
+func loadJKS(fileName string, data []byte, password string) CertificateLocation {
+
+	certificates, err := fromJKS(data, password)
+	if err != nil {
+		return CertificateLocation{Path: fileName, Error: err}
+	}
+
+	return CertificateLocation{
+		Path:         fileName,
+		Certificates: certificates,
+	}
+}
+
+// fromJKS returns certificates from trusted cert entries and private key entry chains. Store password is optional,
+// if it is set, keystore integrity is verified. Private keys are not decrypted. JCEKS secret key entry cannot be
+// skipped, it is returned as certificate with error and the rest of the keystore is not read.
+func fromJKS(data []byte, password string) (Certificates, error) {
+
+	if len(data) < sha1.Size {
+		return nil, errors.New("jks: keystore is too short")
+	}
+	content, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	if password != "" {
+		h := sha1.New()
+		h.Write(jksPassword(password))
+		h.Write([]byte(jksDigestWhitener))
+		h.Write(content)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return nil, errIncorrectStorePassword
+		}
+	}
+
+	r := jksReader{r: bytes.NewReader(content)}
+	magic := r.uint32()
+	version := r.uint32()
+	count := r.uint32()
+	if r.err != nil {
+		return nil, fmt.Errorf("jks: header: %w", r.err)
+	}
+	if version != 1 && version != 2 {
+		return nil, fmt.Errorf("jks: unsupported version %d", version)
+	}
+
+	var certificates Certificates
+	for i := uint32(0); i < count; i++ {
+		tag := r.uint32()
+		alias := r.utf()
+		r.skip(8) // timestamp
+		if r.err != nil {
+			return nil, fmt.Errorf("jks: entry %d: %w", i+1, r.err)
+		}
+
+		switch tag {
+		case jksPrivateKeyEntry:
+			r.skip(int64(r.uint32())) // encrypted private key
+			chainLength := r.uint32()
+			if r.err == nil && chainLength > uint32(r.r.Len()/jksMinCertificateSize(version)) {
+				return nil, fmt.Errorf("jks: entry %s: chain length %d exceeds keystore size", alias, chainLength)
+			}
+			for j := uint32(0); j < chainLength && r.err == nil; j++ {
+				certificates = append(certificates, r.certificate(version, alias, len(certificates)+1))
+			}
+		case jksTrustedCertEntry:
+			certificates = append(certificates, r.certificate(version, alias, len(certificates)+1))
+		case jksSecretKeyEntry:
+			// secret key is serialized java object, we cannot find where it ends, so certificates that were already read
+			// are returned and the entry is flagged
+			err := fmt.Errorf("secret key entries are not supported, %d remaining entries not read", count-i-1)
+			certificates = append(certificates, Certificate{position: len(certificates) + 1, alias: alias, err: err})
+			slog.Debug(fmt.Sprintf("jks: magic %X, version %d, stopped at secret key entry %s", magic, version, alias))
+			return certificates, nil
+		default:
+			return nil, fmt.Errorf("jks: entry %s: unknown tag %d", alias, tag)
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("jks: entry %s: %w", alias, r.err)
+		}
+	}
+	slog.Debug(fmt.Sprintf("jks: magic %X, version %d, loaded %d entries", magic, version, count))
+	return certificates, nil
+}
+
+// jksMinCertificateSize returns size of encoded empty certificate, certificate type (version 2) and length
+func jksMinCertificateSize(version uint32) int {
+
+	if version == 2 {
+		return 2 + 4
+	}
+	return 4
+}
+
+// jksPassword encodes password as java chars (UTF-16 big endian)
+func jksPassword(password string) []byte {
+
+	var out []byte
+	for _, v := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(v>>8), byte(v))
+	}
+	return out
+}
+
+// jksReader reads java DataOutputStream encoded values, first error is kept and all subsequent reads are no-op
+type jksReader struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (j *jksReader) read(n int64) []byte {
+
+	if j.err != nil {
+		return nil
+	}
+	if n > int64(j.r.Len()) {
+		j.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(j.r, b); err != nil {
+		j.err = err
+		return nil
+	}
+	return b
+}
+
+func (j *jksReader) skip(n int64) {
+
+	if j.err != nil {
+		return
+	}
+	if _, err := io.CopyN(io.Discard, j.r, n); err != nil {
+		j.err = err
+	}
+}
+
+func (j *jksReader) uint32() uint32 {
+
+	b := j.read(4)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(b)
+}
+
+// utf reads java modified UTF-8 string, it is the same as UTF-8 for aliases
+func (j *jksReader) utf() string {
+
+	b := j.read(2)
+	if b == nil {
+		return ""
+	}
+	return string(j.read(int64(binary.BigEndian.Uint16(b))))
+}
+
+func (j *jksReader) certificate(version uint32, alias string, position int) Certificate {
+
+	certType := "X.509"
+	if version == 2 {
+		certType = j.utf()
+	}
+	der := j.read(int64(j.uint32()))
+	if j.err != nil {
+		return Certificate{position: position, alias: alias, err: j.err}
+	}
+	if certType != "X.509" {
+		return Certificate{position: position, alias: alias, err: fmt.Errorf("unsupported certificate type %s", certType)}
+	}
+	certificate := fromDERBlock(position, der)
+	certificate.alias = alias
+	return certificate
+}
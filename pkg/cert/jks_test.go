@@ -0,0 +1,91 @@
+package cert
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadJKS(t *testing.T) {
+	t.Run("given JKS keystore and correct password then private key entry chain and trusted cert are loaded", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore.jks"), "changeit")
+		require.NoError(t, location.Error)
+		require.Equal(t, 3, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, "certinfo", location.Certificates[0].Alias())
+		assert.Equal(t, "CN=certinfo test CA", location.Certificates[1].SubjectString())
+		assert.Equal(t, "certinfo", location.Certificates[1].Alias())
+		assert.Equal(t, "CN=certinfo test CA", location.Certificates[2].SubjectString())
+		assert.Equal(t, "certinfo-ca", location.Certificates[2].Alias())
+		assert.Equal(t, 3, location.Certificates[2].position)
+	})
+
+	t.Run("given JCEKS truststore and no password then trusted certs are loaded without integrity check", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "truststore.jceks"), "")
+		require.NoError(t, location.Error)
+		require.Equal(t, 2, len(location.Certificates))
+		assert.Equal(t, "digicert", location.Certificates[0].Alias())
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", location.Certificates[0].SubjectString())
+		assert.Equal(t, "gts", location.Certificates[1].Alias())
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", location.Certificates[1].SubjectString())
+	})
+
+	t.Run("given JCEKS with secret key entry then certificates read before it are loaded and the entry is flagged", func(t *testing.T) {
+		data := loadTestFile(t, "truststore.jceks")
+		content := append([]byte{}, data[:len(data)-sha1.Size]...)
+		binary.BigEndian.PutUint32(content[8:], binary.BigEndian.Uint32(content[8:])+2)
+		// two more entries, secret key entry with alias "key", timestamp and start of serialized java object is read first
+		content = binary.BigEndian.AppendUint32(content, jksSecretKeyEntry)
+		content = append(content, 0, 3, 'k', 'e', 'y')
+		content = append(content, make([]byte, 8)...)
+		content = append(content, 0xAC, 0xED, 0x00, 0x05)
+		content = append(content, make([]byte, sha1.Size)...)
+
+		location := loadCertificate("test", content, "")
+		require.NoError(t, location.Error)
+		require.Equal(t, 3, len(location.Certificates))
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", location.Certificates[0].SubjectString())
+		assert.Equal(t, "CN=GTS Root R1,O=Google Trust Services LLC,C=US", location.Certificates[1].SubjectString())
+		assert.Equal(t, "key", location.Certificates[2].Alias())
+		assert.ErrorContains(t, location.Certificates[2].Error(), "secret key entries are not supported, 1 remaining entries not read")
+	})
+
+	t.Run("given incorrect password then error is returned", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "truststore.jceks"), "incorrect")
+		assert.ErrorIs(t, location.Error, errIncorrectStorePassword)
+	})
+
+	t.Run("given truncated keystore then error is returned", func(t *testing.T) {
+		data := loadTestFile(t, "keystore.jks")
+		location := loadCertificate("test", data[:100], "")
+		assert.Error(t, location.Error)
+	})
+
+	t.Run("given truncated private key entry with large chain length then error is returned", func(t *testing.T) {
+		// header (magic, version 2, 1 entry), private key entry with alias "a", timestamp, empty key and chain length
+		data := binary.BigEndian.AppendUint32(nil, jksMagic)
+		data = binary.BigEndian.AppendUint32(data, 2)
+		data = binary.BigEndian.AppendUint32(data, 1)
+		data = binary.BigEndian.AppendUint32(data, jksPrivateKeyEntry)
+		data = append(data, 0, 1, 'a')
+		data = append(data, make([]byte, 8)...)
+		data = binary.BigEndian.AppendUint32(data, 0)
+		data = binary.BigEndian.AppendUint32(data, 0xFFFFFFFF)
+		data = append(data, make([]byte, sha1.Size)...)
+
+		location := loadCertificate("test", data, "")
+		assert.EqualError(t, location.Error, "jks: entry a: chain length 4294967295 exceeds keystore size")
+		assert.Empty(t, location.Certificates)
+	})
+
+	t.Run("given keystore truncated in private key entry chain then error is returned", func(t *testing.T) {
+		data := loadTestFile(t, "keystore.jks")
+		// digest sized padding is kept after the cut, so the cut is inside the first (private key) entry
+		truncated := append(data[:len(data)/3], make([]byte, sha1.Size)...)
+		location := loadCertificate("test", truncated, "")
+		assert.ErrorContains(t, location.Error, "jks: entry certinfo:")
+	})
+}
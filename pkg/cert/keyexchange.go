@@ -0,0 +1,83 @@
+package cert
+
+import (
+	"context"
+	"crypto/tls"
+	"net/url"
+	"slices"
+)
+
+var (
+	// hybridGroups are post-quantum hybrid key exchange groups supported by crypto/tls (TLS 1.3 only)
+	hybridGroups = []tls.CurveID{tls.X25519MLKEM768}
+	// classicalGroups are elliptic curve key exchange groups supported by crypto/tls
+	classicalGroups = []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}
+)
+
+// KeyExchange is key exchange groups accepted by the server, every group is offered alone in separate handshake
+type KeyExchange struct {
+	Hybrid    []tls.CurveID
+	Classical []tls.CurveID
+}
+
+// PostQuantum checks if the server accepts any post-quantum hybrid key exchange group
+func (k KeyExchange) PostQuantum() bool {
+	return len(k.Hybrid) != 0
+}
+
+// Groups returns names of all accepted groups, hybrid groups first
+func (k KeyExchange) Groups() []string {
+
+	var out []string
+	for _, group := range slices.Concat(k.Hybrid, k.Classical) {
+		out = append(out, group.String())
+	}
+	return out
+}
+
+// probeKeyExchange offers post-quantum hybrid groups and then classical groups one by one and returns accepted groups,
+// probe fails only if the server cannot be connected, rejected handshakes are not accepted
+func probeKeyExchange(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions) (*KeyExchange, error) {
+
+	options.InsecureSkipVerify = true
+	// application protocols are not relevant for key exchange and could be rejected by the server
+	options.NextProtos = nil
+	keyExchange := &KeyExchange{}
+	// hybrid groups are negotiated only by TLS 1.3
+	hybridOptions := options
+	hybridOptions.TLSVersion = tls.VersionTLS13
+	hybrid, err := acceptedGroups(ctx, addr, proxy, hybridOptions, hybridGroups)
+	if err != nil {
+		return nil, err
+	}
+	keyExchange.Hybrid = hybrid
+
+	classical, err := acceptedGroups(ctx, addr, proxy, options, classicalGroups)
+	if err != nil {
+		return nil, err
+	}
+	keyExchange.Classical = classical
+	return keyExchange, nil
+}
+
+func acceptedGroups(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions, groups []tls.CurveID) ([]tls.CurveID, error) {
+
+	var accepted []tls.CurveID
+	for _, group := range groups {
+		options.CurvePreferences = []tls.CurveID{group}
+		options.probeWait(ctx)
+		conn, _, err := dialTLS(ctx, addr, proxy, options)
+		if err != nil {
+			if isConnectError(ctx, err) {
+				return nil, err
+			}
+			continue
+		}
+		negotiated := conn.ConnectionState().CurveID
+		conn.Close()
+		if negotiated == group {
+			accepted = append(accepted, group)
+		}
+	}
+	return accepted, nil
+}
@@ -0,0 +1,91 @@
+package cert
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_keyExchange(t *testing.T) {
+	t.Run("given server with default groups then hybrid and classical groups are accepted", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, KeyExchange: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.KeyExchange)
+		assert.True(t, location.KeyExchange.PostQuantum())
+		assert.Equal(t, []tls.CurveID{tls.X25519MLKEM768}, location.KeyExchange.Hybrid)
+		assert.Equal(t, []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384, tls.CurveP521}, location.KeyExchange.Classical)
+	})
+
+	t.Run("given server with classical groups only then post-quantum is not supported", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.CurvePreferences = []tls.CurveID{tls.X25519, tls.CurveP256}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, KeyExchange: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.KeyExchange)
+		assert.False(t, location.KeyExchange.PostQuantum())
+		assert.Equal(t, []string{"X25519", "CurveP256"}, location.KeyExchange.Groups())
+	})
+
+	t.Run("given tls 1.2 server then post-quantum is not supported", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.MaxVersion = tls.VersionTLS12
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, KeyExchange: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.KeyExchange)
+		assert.Empty(t, location.KeyExchange.Hybrid)
+		assert.NotEmpty(t, location.KeyExchange.Classical)
+	})
+
+	t.Run("given server with untrusted certificate then handshake fails and key exchange is probed", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{KeyExchange: true})
+		require.Error(t, location.Error)
+		require.NoError(t, location.KeyExchangeError)
+		require.NotNil(t, location.KeyExchange)
+		assert.True(t, location.KeyExchange.PostQuantum())
+	})
+
+	t.Run("given probe wait then every group probe waits", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+
+		var waits atomic.Int32
+		options := NetworkOptions{InsecureSkipVerify: true, KeyExchange: true, ProbeWait: func(context.Context) { waits.Add(1) }}
+		location := LoadCertificatesFromNetwork(t.Context(), addr, options)
+		require.NoError(t, location.Error)
+		assert.Equal(t, int32(len(hybridGroups)+len(classicalGroups)), waits.Load())
+	})
+
+	t.Run("given server cannot be connected then key exchange error is set", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{KeyExchange: true})
+		require.Error(t, location.Error)
+		assert.Error(t, location.KeyExchangeError)
+		assert.Nil(t, location.KeyExchange)
+	})
+}
@@ -0,0 +1,147 @@
+package cert
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+)
+
+// kubernetesObject is a subset of Secret, ConfigMap and List fields
+type kubernetesObject struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name      string `yaml:"name"`
+		Namespace string `yaml:"namespace"`
+	} `yaml:"metadata"`
+	Data       map[string]string  `yaml:"data"`
+	StringData map[string]string  `yaml:"stringData"`
+	BinaryData map[string]string  `yaml:"binaryData"`
+	Items      []kubernetesObject `yaml:"items"`
+}
+
+func (o kubernetesObject) isKubernetesObject() bool {
+	return o.APIVersion != "" && o.Kind != ""
+}
+
+func (o kubernetesObject) name() string {
+	if o.Metadata.Namespace == "" {
+		return o.Metadata.Name
+	}
+	return fmt.Sprintf("%s/%s", o.Metadata.Namespace, o.Metadata.Name)
+}
+
+func loadKubernetes(fileName string, objects []kubernetesObject) CertificateLocations {
+
+	var locations CertificateLocations
+	for _, object := range objects {
+		locations = append(locations, kubernetesObjectLocations(object)...)
+	}
+	if len(locations) == 0 {
+		return CertificateLocations{{Path: fileName, Error: errors.New("no certificates found in kubernetes manifest")}}
+	}
+	return locations
+}
+
+// kubernetesObjects returns all kubernetes objects from YAML or JSON manifest (single document, multi document stream
+// or List), List objects are flattened
+func kubernetesObjects(data []byte) ([]kubernetesObject, error) {
+
+	var objects []kubernetesObject
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := decoder.Decode(&node); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		var object kubernetesObject
+		if err := node.Decode(&object); err != nil {
+			// not every kubernetes object has string data, we are only interested in secrets and config maps
+			slog.Debug(fmt.Sprintf("skipping kubernetes document: %v", err))
+			continue
+		}
+		if !object.isKubernetesObject() {
+			continue
+		}
+		objects = append(objects, flattenKubernetesObject(object)...)
+	}
+	return objects, nil
+}
+
+func flattenKubernetesObject(object kubernetesObject) []kubernetesObject {
+
+	if len(object.Items) == 0 {
+		return []kubernetesObject{object}
+	}
+	var objects []kubernetesObject
+	for _, item := range object.Items {
+		objects = append(objects, flattenKubernetesObject(item)...)
+	}
+	return objects
+}
+
+// kubernetesObjectLocations returns location for every key with PEM certificate, location is named
+// <namespace>/<name>:<key>
+func kubernetesObjectLocations(object kubernetesObject) CertificateLocations {
+
+	values := make(map[string][]byte)
+	switch object.Kind {
+	case "Secret":
+		for k, v := range object.Data {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				slog.Debug(fmt.Sprintf("secret %s key %s: %v", object.name(), k, err))
+				continue
+			}
+			values[k] = decoded
+		}
+		for k, v := range object.StringData {
+			values[k] = []byte(v)
+		}
+	case "ConfigMap":
+		for k, v := range object.Data {
+			values[k] = []byte(v)
+		}
+		for k, v := range object.BinaryData {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				slog.Debug(fmt.Sprintf("config map %s key %s: %v", object.name(), k, err))
+				continue
+			}
+			values[k] = decoded
+		}
+	default:
+		return nil
+	}
+
+	var locations CertificateLocations
+	for _, k := range slices.Sorted(maps.Keys(values)) {
+		if !hasPEMCertificate(values[k]) {
+			continue
+		}
+		path := fmt.Sprintf("%s:%s", object.name(), k)
+		certificates, err := FromBytes(values[k])
+		locations = append(locations, CertificateLocation{Path: path, Error: err, Certificates: certificates})
+	}
+	return locations
+}
+
+// hasPEMCertificate checks if data contains PEM certificate (or PKCS7) block, keys (e.g. tls.key) are ignored
+func hasPEMCertificate(data []byte) bool {
+	for _, blockType := range []string{certificateBlockType, pkcs7BlockType} {
+		if bytes.Contains(data, []byte(fmt.Sprintf("-----BEGIN %s-----", blockType))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package cert
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadKubernetes(t *testing.T) {
+	t.Run("given multi document YAML stream then location is loaded for every certificate key", func(t *testing.T) {
+		locations := loadCertificates("test", loadTestFile(t, "kubernetes.yaml"), "")
+		require.Equal(t, 3, len(locations))
+
+		assert.Equal(t, "certinfo/certinfo-tls:ca.crt", locations[0].Path)
+		require.Equal(t, 1, len(locations[0].Certificates))
+		assert.Equal(t, "CN=certinfo test CA", locations[0].Certificates[0].SubjectString())
+
+		assert.Equal(t, "certinfo/certinfo-tls:tls.crt", locations[1].Path)
+		require.Equal(t, 2, len(locations[1].Certificates))
+		assert.Equal(t, "CN=certinfo.test", locations[1].Certificates[0].SubjectString())
+
+		assert.Equal(t, "kube-system/root-ca:root.pem", locations[2].Path)
+		require.Equal(t, 1, len(locations[2].Certificates))
+		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", locations[2].Certificates[0].SubjectString())
+	})
+
+	t.Run("given JSON List then location is loaded for every item certificate key", func(t *testing.T) {
+		locations := loadCertificates("test", loadTestFile(t, "kubernetes.json"), "")
+		require.Equal(t, 2, len(locations))
+		assert.Equal(t, "default/certinfo-ca:ca.crt", locations[0].Path)
+		assert.Equal(t, "string-data:cert.pem", locations[1].Path)
+		require.Equal(t, 1, len(locations[1].Certificates))
+		assert.Nil(t, locations[1].Certificates[0].Error())
+	})
+
+	t.Run("given kubernetes manifest without certificates then error is returned", func(t *testing.T) {
+		manifest := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\ndata:\n  key: value\n")
+		locations := loadCertificates("test", manifest, "")
+		require.Equal(t, 1, len(locations))
+		assert.Equal(t, "test", locations[0].Path)
+		assert.Error(t, locations[0].Error)
+	})
+
+	t.Run("given PEM certificate then it is not loaded as kubernetes manifest", func(t *testing.T) {
+		locations := loadCertificates("test", loadTestFile(t, "bundle.pem"), "")
+		require.Equal(t, 1, len(locations))
+		assert.Equal(t, "test", locations[0].Path)
+		assert.Equal(t, 2, len(locations[0].Certificates))
+	})
+}
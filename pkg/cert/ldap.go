@@ -0,0 +1,118 @@
+package cert
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	ldapStartTLSOID         = "1.3.6.1.4.1.1466.20037"
+	ldapExtendedRequestTag  = 23
+	ldapExtendedResponseTag = 24
+	// maximum size of LDAP response, StartTLS response is small, anything bigger is not LDAP
+	ldapMaxMessageSize = 64 * 1024
+)
+
+// LDAPMessage ::= SEQUENCE {
+// messageID       MessageID,
+// protocolOp      CHOICE { ..., extendedReq ExtendedRequest, extendedResp ExtendedResponse, ... },
+// controls        [0] Controls OPTIONAL }
+type ldapMessage struct {
+	MessageID  int
+	ProtocolOp asn1.RawValue
+	Controls   asn1.RawValue `asn1:"optional,tag:0"`
+}
+
+// ExtendedRequest ::= [APPLICATION 23] SEQUENCE {
+// requestName      [0] LDAPOID,
+// requestValue     [1] OCTET STRING OPTIONAL }
+type ldapExtendedRequest struct {
+	RequestName []byte `asn1:"tag:0"`
+}
+
+// ExtendedResponse ::= [APPLICATION 24] SEQUENCE {
+// resultCode         ENUMERATED,
+// matchedDN          LDAPDN,
+// diagnosticMessage  LDAPString,
+// referral           [3] Referral OPTIONAL,
+// responseName       [10] LDAPOID OPTIONAL,
+// responseValue      [11] OCTET STRING OPTIONAL }
+type ldapExtendedResponse struct {
+	ResultCode        asn1.Enumerated
+	MatchedDN         []byte
+	DiagnosticMessage []byte
+}
+
+// LDAP, RFC 4511 StartTLS extended operation
+func ldapStartTLS(conn net.Conn, _ string) error {
+
+	requestOp, err := asn1.MarshalWithParams(ldapExtendedRequest{RequestName: []byte(ldapStartTLSOID)},
+		fmt.Sprintf("application,tag:%d", ldapExtendedRequestTag))
+	if err != nil {
+		return err
+	}
+	request, err := asn1.Marshal(ldapMessage{MessageID: 1, ProtocolOp: asn1.RawValue{FullBytes: requestOp}})
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	response, err := readBERElement(conn, ldapMaxMessageSize)
+	if err != nil {
+		return fmt.Errorf("extended response: %w", err)
+	}
+	var message ldapMessage
+	if _, err := asn1.Unmarshal(response, &message); err != nil {
+		return fmt.Errorf("extended response: %w", err)
+	}
+	if message.ProtocolOp.Class != asn1.ClassApplication || message.ProtocolOp.Tag != ldapExtendedResponseTag {
+		return fmt.Errorf("extended response: unexpected protocol operation %d", message.ProtocolOp.Tag)
+	}
+	var extendedResponse ldapExtendedResponse
+	if _, err := asn1.UnmarshalWithParams(message.ProtocolOp.FullBytes, &extendedResponse,
+		fmt.Sprintf("application,tag:%d", ldapExtendedResponseTag)); err != nil {
+		return fmt.Errorf("extended response: %w", err)
+	}
+	if extendedResponse.ResultCode != 0 {
+		return fmt.Errorf("result code %d: %s", extendedResponse.ResultCode, extendedResponse.DiagnosticMessage)
+	}
+	return nil
+}
+
+// readBERElement reads single BER (definite length) element from the reader, element is returned with tag and length
+func readBERElement(r io.Reader, maxSize int) ([]byte, error) {
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, errors.New("unsupported length encoding")
+		}
+		lengthBytes := make([]byte, n)
+		if _, err := io.ReadFull(r, lengthBytes); err != nil {
+			return nil, err
+		}
+		header = append(header, lengthBytes...)
+		length = 0
+		for _, b := range lengthBytes {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > maxSize {
+		return nil, fmt.Errorf("element size %d exceeds %d bytes", length, maxSize)
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return append(header, content...), nil
+}
@@ -2,8 +2,13 @@ package cert
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"os"
 	"testing"
 	"time"
 
@@ -28,10 +33,220 @@ func Test_nameFormat(t *testing.T) {
 	})
 }
 
+func TestCertificateLocation_Name(t *testing.T) {
+	t.Run("given server name then it is part of the name", func(t *testing.T) {
+		location := CertificateLocation{Path: "10.0.0.5:443", ServerName: "api.example.com", TLSVersion: tls.VersionTLS13}
+		assert.Equal(t, "10.0.0.5:443 (SNI api.example.com) TLS 1.3", location.Name())
+	})
+
+	t.Run("given no server name then name is path and tls version", func(t *testing.T) {
+		location := CertificateLocation{Path: "example.com:443", TLSVersion: tls.VersionTLS13}
+		assert.Equal(t, "example.com:443 TLS 1.3", location.Name())
+	})
+
+	t.Run("given proxy then it is part of the name", func(t *testing.T) {
+		location := CertificateLocation{Path: "example.com:443", Proxy: "http://proxy:3128", TLSVersion: tls.VersionTLS13}
+		assert.Equal(t, "example.com:443 (via http://proxy:3128) TLS 1.3", location.Name())
+	})
+
+	t.Run("given ip then it is part of the name", func(t *testing.T) {
+		location := CertificateLocation{Path: "example.com:443", IP: "10.0.0.5", TLSVersion: tls.VersionTLS13}
+		assert.Equal(t, "example.com:443 (IP 10.0.0.5) TLS 1.3", location.Name())
+	})
+}
+
+func TestCertificateLocation_Handshake(t *testing.T) {
+	t.Run("given network location then handshake parameters are returned", func(t *testing.T) {
+		location := CertificateLocation{
+			TLSVersion:  tls.VersionTLS13,
+			CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+			CurveID:     tls.X25519MLKEM768,
+			ALPN:        "h2",
+			SNI:         "example.com",
+			Resumed:     true,
+		}
+		assert.Equal(t, "TLS_AES_128_GCM_SHA256, X25519MLKEM768, ALPN h2, SNI example.com, resumed", location.Handshake())
+	})
+
+	t.Run("given deprecated cipher suite and no sni then they are flagged", func(t *testing.T) {
+		location := CertificateLocation{TLSVersion: tls.VersionTLS12, CipherSuite: tls.TLS_RSA_WITH_AES_128_CBC_SHA}
+		assert.Equal(t, "TLS_RSA_WITH_AES_128_CBC_SHA - Deprecated!, no SNI", location.Handshake())
+	})
+
+	t.Run("given sni set by server name then it is not repeated in handshake", func(t *testing.T) {
+		location := CertificateLocation{
+			Path:        "10.0.0.5:443",
+			ServerName:  "api.example.com",
+			TLSVersion:  tls.VersionTLS13,
+			CipherSuite: tls.TLS_AES_128_GCM_SHA256,
+			SNI:         "api.example.com",
+		}
+		assert.Equal(t, "TLS_AES_128_GCM_SHA256", location.Handshake())
+		assert.Equal(t, "10.0.0.5:443 (SNI api.example.com) TLS 1.3", location.Name())
+	})
+
+	t.Run("given file location then handshake is empty", func(t *testing.T) {
+		assert.Equal(t, "", CertificateLocation{Path: "cert.pem"}.Handshake())
+	})
+}
+
+func Test_isDeprecatedCipherSuite(t *testing.T) {
+	assert.False(t, isDeprecatedCipherSuite(tls.TLS_AES_256_GCM_SHA384))
+	assert.False(t, isDeprecatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256))
+	assert.False(t, isDeprecatedCipherSuite(tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256))
+	assert.True(t, isDeprecatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA))
+	assert.True(t, isDeprecatedCipherSuite(tls.TLS_RSA_WITH_AES_256_GCM_SHA384))
+	assert.True(t, isDeprecatedCipherSuite(tls.TLS_ECDHE_RSA_WITH_RC4_128_SHA))
+	assert.True(t, isDeprecatedCipherSuite(tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA))
+}
+
+func TestCertificateLocations_MarkCertificateMismatch(t *testing.T) {
+	certificate := loadTestCertificates(t, "cert.pem")
+	other := loadTestCertificates(t, "intermediate_same_issuer_and_subject.pem")
+
+	t.Run("given one location serves different certificate then only that location is marked", func(t *testing.T) {
+		locations := CertificateLocations{
+			{Path: "example.com:443", IP: "10.0.0.1", Certificates: certificate},
+			{Path: "example.com:443", IP: "10.0.0.2", Certificates: other},
+			{Path: "example.com:443", IP: "10.0.0.3", Certificates: certificate},
+			{Path: "example.com:443", IP: "10.0.0.4", Error: errors.New("connection refused")},
+		}.MarkCertificateMismatch()
+		assert.False(t, locations[0].CertificateMismatch)
+		assert.True(t, locations[1].CertificateMismatch)
+		assert.False(t, locations[2].CertificateMismatch)
+		assert.False(t, locations[3].CertificateMismatch)
+	})
+
+	t.Run("given no most common certificate then all locations are marked", func(t *testing.T) {
+		locations := CertificateLocations{
+			{Path: "example.com:443", IP: "10.0.0.1", Certificates: certificate},
+			{Path: "example.com:443", IP: "10.0.0.2", Certificates: other},
+		}.MarkCertificateMismatch()
+		assert.True(t, locations[0].CertificateMismatch)
+		assert.True(t, locations[1].CertificateMismatch)
+	})
+
+	t.Run("given same certificate then no location is marked", func(t *testing.T) {
+		locations := CertificateLocations{
+			{Path: "example.com:443", IP: "10.0.0.1", Certificates: certificate},
+			{Path: "example.com:443", IP: "10.0.0.2", Certificates: certificate},
+		}.MarkCertificateMismatch()
+		assert.False(t, locations[0].CertificateMismatch)
+		assert.False(t, locations[1].CertificateMismatch)
+	})
+}
+
+func TestCertificateLocation_LabelsString(t *testing.T) {
+	t.Run("given labels then they are sorted by key", func(t *testing.T) {
+		location := CertificateLocation{Labels: map[string]string{"owner": "team-a", "env": "prod"}}
+		assert.Equal(t, "env=prod, owner=team-a", location.LabelsString())
+	})
+}
+
+func TestCertificateLocation_UnmatchedHostnames(t *testing.T) {
+	t.Run("given expected hostnames then hostnames not valid for leaf certificate are returned", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore.p12"), "test")
+		require.NoError(t, location.Error)
+		location.ExpectedHostnames = []string{"certinfo.test", "www.certinfo.test"}
+		assert.Equal(t, []string{"www.certinfo.test"}, location.UnmatchedHostnames())
+	})
+}
+
+func TestLoadCertificatesFromNetwork(t *testing.T) {
+	t.Run("given server that does not respond then handshake timeout error is returned", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			io.Copy(io.Discard, conn)
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{HandshakeTimeout: 50 * time.Millisecond})
+		require.Error(t, location.Error)
+		assert.False(t, location.Cancelled())
+		assert.ErrorIs(t, location.Error, os.ErrDeadlineExceeded)
+	})
+
+	t.Run("given ip then connection is made to ip and host is used as server name", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		serverNames := make(chan string, 1)
+		tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverNames <- hello.ServerName
+			return nil, nil
+		}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+
+		location := LoadCertificatesFromNetwork(t.Context(), "certinfo.test:"+port, NetworkOptions{IP: "127.0.0.1", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Equal(t, "127.0.0.1", location.IP)
+		assert.Equal(t, "certinfo.test", <-serverNames)
+		require.Equal(t, 1, len(location.Certificates))
+	})
+
+	t.Run("given server then handshake parameters are set", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.NextProtos = []string{"h2"}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+
+		location := LoadCertificatesFromNetwork(t.Context(), "certinfo.test:"+port, NetworkOptions{IP: "127.0.0.1",
+			InsecureSkipVerify: true, NextProtos: []string{"h2", "http/1.1"}})
+		require.NoError(t, location.Error)
+		assert.Equal(t, uint16(tls.VersionTLS13), location.TLSVersion)
+		assert.NotZero(t, location.CipherSuite)
+		assert.NotZero(t, location.CurveID)
+		assert.Equal(t, "h2", location.ALPN)
+		assert.Equal(t, "certinfo.test", location.SNI)
+		assert.False(t, location.Resumed)
+
+		location = LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Equal(t, "", location.SNI)
+		assert.Equal(t, "", location.ALPN)
+	})
+
+	t.Run("given server with other application protocols and alpn is not set then handshake succeeds", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.NextProtos = []string{"mqtt"}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Equal(t, "", location.ALPN)
+
+		location = LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, NextProtos: []string{"h2"}})
+		assert.ErrorContains(t, location.Error, "no application protocol")
+	})
+
+	t.Run("given context is cancelled during handshake then location is cancelled", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			io.Copy(io.Discard, conn)
+		})
+		ctx, cancel := context.WithCancelCause(t.Context())
+		time.AfterFunc(50*time.Millisecond, func() { cancel(errors.New("received interrupt")) })
+		location := LoadCertificatesFromNetwork(ctx, addr, NetworkOptions{})
+		assert.True(t, location.Cancelled())
+		assert.EqualError(t, location.Error, "cancelled: received interrupt")
+	})
+
+	t.Run("given context is done then file location is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		locations := LoadCertificatesFromFile(ctx, "testdata/cert.pem", "")
+		require.Equal(t, 1, len(locations))
+		assert.True(t, locations[0].Cancelled())
+	})
+}
+
 func Test_loadCertificate(t *testing.T) {
 	t.Run("given valid certificate then cert location is loaded", func(t *testing.T) {
 		certificate := loadTestFile(t, "cert.pem")
-		cert := loadCertificate("test", certificate)
+		cert := loadCertificate("test", certificate, "")
 		require.Equal(t, 1, len(cert.Certificates))
 		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", cert.Certificates[0].SubjectString())
 	})
@@ -39,7 +254,7 @@ func Test_loadCertificate(t *testing.T) {
 	t.Run("given certificate with extra spaces then cert location is loaded", func(t *testing.T) {
 		certificate := loadTestFile(t, "cert.pem")
 		certificate = bytes.Join([][]byte{[]byte("   "), certificate}, []byte(""))
-		cert := loadCertificate("test", certificate)
+		cert := loadCertificate("test", certificate, "")
 		require.Equal(t, 1, len(cert.Certificates))
 		assert.Equal(t, "CN=DigiCert Global Root G2,OU=www.digicert.com,O=DigiCert Inc,C=US", cert.Certificates[0].SubjectString())
 	})
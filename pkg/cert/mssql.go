@@ -0,0 +1,174 @@
+package cert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	tdsPreLogin       = 0x12
+	tdsTabularResult  = 0x04
+	tdsStatusEOM      = 0x01
+	tdsHeaderSize     = 8
+	tdsMaxPacketSize  = 4096
+	tdsMaxMessageSize = 64 * 1024
+
+	preLoginVersion    = 0x00
+	preLoginEncryption = 0x01
+	preLoginTerminator = 0xff
+
+	encryptOn     = 0x01
+	encryptNotSup = 0x02
+)
+
+// MSSQL, MS-TDS PRELOGIN exchange, TLS handshake that follows is wrapped in PRELOGIN packets (see tdsConn)
+func mssqlStartTLS(conn net.Conn, _ string) error {
+
+	// option tokens: token (1), offset (2), length (2), followed by terminator and option data
+	request := []byte{
+		preLoginVersion, 0, 11, 0, 6,
+		preLoginEncryption, 0, 17, 0, 1,
+		preLoginTerminator,
+		0, 0, 0, 0, 0, 0, // version and sub build
+		encryptOn,
+	}
+	if err := writeTDSMessage(conn, tdsPreLogin, 1, request); err != nil {
+		return err
+	}
+
+	packetType, response, err := readTDSMessage(conn)
+	if err != nil {
+		return fmt.Errorf("prelogin: %w", err)
+	}
+	if packetType != tdsTabularResult {
+		return fmt.Errorf("prelogin: unexpected packet type %d", packetType)
+	}
+	encryption, err := preLoginOption(response, preLoginEncryption)
+	if err != nil {
+		return fmt.Errorf("prelogin: %w", err)
+	}
+	if len(encryption) != 1 {
+		return errors.New("prelogin: invalid encryption option")
+	}
+	if encryption[0] == encryptNotSup {
+		return errors.New("server does not support encryption")
+	}
+	return nil
+}
+
+// preLoginOption returns option data from PRELOGIN message
+func preLoginOption(message []byte, token byte) ([]byte, error) {
+
+	for i := 0; i < len(message) && message[i] != preLoginTerminator; i += 5 {
+		if i+5 > len(message) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		if message[i] != token {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint16(message[i+1 : i+3]))
+		length := int(binary.BigEndian.Uint16(message[i+3 : i+5]))
+		if offset+length > len(message) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return message[offset : offset+length], nil
+	}
+	return nil, fmt.Errorf("option %d not found", token)
+}
+
+// readTDSMessage reads TDS packets until end of message and returns packet type and joined payload
+func readTDSMessage(r io.Reader) (byte, []byte, error) {
+
+	var message []byte
+	for {
+		packetType, status, payload, err := readTDSPacket(r)
+		if err != nil {
+			return 0, nil, err
+		}
+		message = append(message, payload...)
+		if len(message) > tdsMaxMessageSize {
+			return 0, nil, fmt.Errorf("message exceeds %d bytes", tdsMaxMessageSize)
+		}
+		if status&tdsStatusEOM != 0 {
+			return packetType, message, nil
+		}
+	}
+}
+
+// readTDSPacket reads packet with type (1), status (1), length (2), spid (2), packet id (1) and window (1) header
+func readTDSPacket(r io.Reader) (byte, byte, []byte, error) {
+
+	header := make([]byte, tdsHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, 0, nil, err
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < tdsHeaderSize {
+		return 0, 0, nil, fmt.Errorf("invalid tds packet length %d", length)
+	}
+	payload := make([]byte, length-tdsHeaderSize)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, 0, nil, err
+	}
+	return header[0], header[1], payload, nil
+}
+
+// writeTDSMessage writes message split to packets of maximum packet size
+func writeTDSMessage(w io.Writer, packetType, packetID byte, message []byte) error {
+
+	for {
+		payload := message[:min(len(message), tdsMaxPacketSize-tdsHeaderSize)]
+		message = message[len(payload):]
+		var status byte
+		if len(message) == 0 {
+			status = tdsStatusEOM
+		}
+		length := len(payload) + tdsHeaderSize
+		header := []byte{packetType, status, byte(length >> 8), byte(length), 0, 0, packetID, 0}
+		if _, err := w.Write(append(header, payload...)); err != nil {
+			return err
+		}
+		if len(message) == 0 {
+			return nil
+		}
+		packetID++
+	}
+}
+
+// tdsConn wraps TLS handshake records in TDS PRELOGIN packets, it is used only for the handshake, after that TLS
+// records are sent without TDS framing
+type tdsConn struct {
+	net.Conn
+	buffer   []byte
+	packetID byte
+}
+
+func newTDSConn(conn net.Conn) net.Conn {
+	return &tdsConn{Conn: conn, packetID: 1}
+}
+
+func (c *tdsConn) Read(b []byte) (int, error) {
+
+	if len(c.buffer) == 0 {
+		_, _, payload, err := readTDSPacket(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		c.buffer = payload
+	}
+	n := copy(b, c.buffer)
+	c.buffer = c.buffer[n:]
+	return n, nil
+}
+
+func (c *tdsConn) Write(b []byte) (int, error) {
+
+	if err := writeTDSMessage(c.Conn, tdsPreLogin, c.packetID, b); err != nil {
+		return 0, err
+	}
+	c.packetID++
+	return len(b), nil
+}
@@ -0,0 +1,66 @@
+package cert
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_mssql(t *testing.T) {
+	t.Run("given mssql server then certificates are loaded from tls handshake wrapped in prelogin packets", func(t *testing.T) {
+		addr := startTestServer(t, fakeMSSQLServer(testServerTLSConfig(t), encryptOn))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "mssql", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, uint16(tls.VersionTLS13), location.TLSVersion)
+	})
+
+	t.Run("given mssql server without encryption support then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, fakeMSSQLServer(testServerTLSConfig(t), encryptNotSup))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "mssql", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "server does not support encryption")
+	})
+}
+
+func Test_writeTDSMessage(t *testing.T) {
+	t.Run("given message bigger than packet size then it is split to multiple packets", func(t *testing.T) {
+		server, client := net.Pipe()
+		defer server.Close()
+		message := make([]byte, tdsMaxPacketSize*2)
+		go func() {
+			writeTDSMessage(client, tdsPreLogin, 1, message)
+			client.Close()
+		}()
+		packetType, received, err := readTDSMessage(server)
+		require.NoError(t, err)
+		assert.Equal(t, byte(tdsPreLogin), packetType)
+		assert.Equal(t, message, received)
+	})
+}
+
+func fakeMSSQLServer(tlsConfig *tls.Config, encryption byte) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		packetType, request, err := readTDSMessage(conn)
+		if err != nil || packetType != tdsPreLogin {
+			return
+		}
+		if _, err := preLoginOption(request, preLoginEncryption); err != nil {
+			return
+		}
+		response := []byte{
+			preLoginVersion, 0, 11, 0, 6,
+			preLoginEncryption, 0, 17, 0, 1,
+			preLoginTerminator,
+			16, 0, 0x07, 0xd0, 0, 0,
+			encryption,
+		}
+		writeTDSMessage(conn, tdsTabularResult, 1, response)
+		if encryption != encryptNotSup {
+			tls.Server(newTDSConn(conn), tlsConfig).Handshake()
+		}
+	}
+}
@@ -0,0 +1,418 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+)
+
+var oidOCSPBasicResponse = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+
+const (
+	OCSPStatusGood    = "good"
+	OCSPStatusRevoked = "revoked"
+	OCSPStatusUnknown = "unknown"
+)
+
+// OCSPResponse is decoded OCSP response for a certificate
+type OCSPResponse struct {
+	// Responder is responder name or key hash (if the responder is identified by key)
+	Responder  string
+	Status     string
+	ProducedAt time.Time
+	ThisUpdate time.Time
+	// NextUpdate is zero if the responder did not set it (newer revocation information is always available)
+	NextUpdate time.Time
+	// RevokedAt and RevocationReason are set only for revoked certificate
+	RevokedAt        time.Time
+	RevocationReason string
+	// SignatureError is set if the response signature could not be verified by the issuer or delegated responder
+	// certificate issued by the issuer
+	SignatureError error
+}
+
+// IsExpired checks if the response is past its next update
+func (o OCSPResponse) IsExpired() bool {
+	return !o.NextUpdate.IsZero() && time.Now().After(o.NextUpdate)
+}
+
+// OCSPResponse ::= SEQUENCE {
+// responseStatus OCSPResponseStatus,
+// responseBytes  [0] EXPLICIT ResponseBytes OPTIONAL }
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+// ResponseBytes ::= SEQUENCE {
+// responseType OBJECT IDENTIFIER,
+// response     OCTET STRING }
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+// BasicOCSPResponse ::= SEQUENCE {
+// tbsResponseData    ResponseData,
+// signatureAlgorithm AlgorithmIdentifier,
+// signature          BIT STRING,
+// certs              [0] EXPLICIT SEQUENCE OF Certificate OPTIONAL }
+type ocspBasicResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+// ResponseData ::= SEQUENCE {
+// version            [0] EXPLICIT Version DEFAULT v1,
+// responderID        ResponderID,
+// producedAt         GeneralizedTime,
+// responses          SEQUENCE OF SingleResponse,
+// responseExtensions [1] EXPLICIT Extensions OPTIONAL }
+//
+// ResponderID ::= CHOICE {
+// byName [1] Name,
+// byKey  [2] KeyHash }
+type ocspResponseData struct {
+	Version            int `asn1:"optional,default:0,explicit,tag:0"`
+	ResponderID        asn1.RawValue
+	ProducedAt         time.Time `asn1:"generalized"`
+	Responses          []ocspSingleResponse
+	ResponseExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// SingleResponse ::= SEQUENCE {
+// certID           CertID,
+// certStatus       CertStatus,
+// thisUpdate       GeneralizedTime,
+// nextUpdate       [0] EXPLICIT GeneralizedTime OPTIONAL,
+// singleExtensions [1] EXPLICIT Extensions OPTIONAL }
+//
+// CertStatus ::= CHOICE {
+// good    [0] IMPLICIT NULL,
+// revoked [1] IMPLICIT RevokedInfo,
+// unknown [2] IMPLICIT UnknownInfo }
+type ocspSingleResponse struct {
+	CertID           ocspCertID
+	CertStatus       asn1.RawValue
+	ThisUpdate       time.Time        `asn1:"generalized"`
+	NextUpdate       time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	SingleExtensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+// CertID ::= SEQUENCE {
+// hashAlgorithm  AlgorithmIdentifier,
+// issuerNameHash OCTET STRING,
+// issuerKeyHash  OCTET STRING,
+// serialNumber   CertificateSerialNumber }
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+// RevokedInfo ::= SEQUENCE {
+// revocationTime   GeneralizedTime,
+// revocationReason [0] EXPLICIT CRLReason OPTIONAL }
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional,default:-1"`
+}
+
+var ocspResponseStatuses = map[asn1.Enumerated]string{
+	1: "malformed request",
+	2: "internal error",
+	3: "try later",
+	5: "signature required",
+	6: "unauthorized",
+}
+
+// CRLReason ::= ENUMERATED
+var revocationReasons = map[asn1.Enumerated]string{
+	0:  "unspecified",
+	1:  "key compromise",
+	2:  "CA compromise",
+	3:  "affiliation changed",
+	4:  "superseded",
+	5:  "cessation of operation",
+	6:  "certificate hold",
+	8:  "remove from CRL",
+	9:  "privilege withdrawn",
+	10: "AA compromise",
+}
+
+var ocspHashAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+var ocspSignatureAlgorithms = map[string]x509.SignatureAlgorithm{
+	"1.2.840.113549.1.1.5":  x509.SHA1WithRSA,
+	"1.2.840.113549.1.1.11": x509.SHA256WithRSA,
+	"1.2.840.113549.1.1.12": x509.SHA384WithRSA,
+	"1.2.840.113549.1.1.13": x509.SHA512WithRSA,
+	"1.2.840.10045.4.1":     x509.ECDSAWithSHA1,
+	"1.2.840.10045.4.3.2":   x509.ECDSAWithSHA256,
+	"1.2.840.10045.4.3.3":   x509.ECDSAWithSHA384,
+	"1.2.840.10045.4.3.4":   x509.ECDSAWithSHA512,
+	"1.3.101.112":           x509.PureEd25519,
+}
+
+// ParseOCSPResponse decodes DER OCSP response for the certificate, signature is verified by the issuer or delegated
+// responder certificate issued by the issuer. Signature error is set in the response, error is returned only if the
+// response cannot be decoded, is not successful or does not contain the certificate.
+func ParseOCSPResponse(der []byte, certificate, issuer *x509.Certificate) (OCSPResponse, error) {
+
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil {
+		return OCSPResponse{}, fmt.Errorf("cannot parse OCSP response: %w", err)
+	} else if len(rest) != 0 {
+		return OCSPResponse{}, errors.New("cannot parse OCSP response: trailing data")
+	}
+	if resp.Status != 0 {
+		if status, ok := ocspResponseStatuses[resp.Status]; ok {
+			return OCSPResponse{}, fmt.Errorf("OCSP response status %s", status)
+		}
+		return OCSPResponse{}, fmt.Errorf("OCSP response status %d", resp.Status)
+	}
+	if !resp.ResponseBytes.ResponseType.Equal(oidOCSPBasicResponse) {
+		return OCSPResponse{}, fmt.Errorf("unsupported OCSP response type %s", resp.ResponseBytes.ResponseType)
+	}
+
+	var basic ocspBasicResponse
+	if _, err := asn1.Unmarshal(resp.ResponseBytes.Response, &basic); err != nil {
+		return OCSPResponse{}, fmt.Errorf("cannot parse OCSP basic response: %w", err)
+	}
+	var data ocspResponseData
+	if _, err := asn1.Unmarshal(basic.TBSResponseData.FullBytes, &data); err != nil {
+		return OCSPResponse{}, fmt.Errorf("cannot parse OCSP response data: %w", err)
+	}
+	single, err := ocspSingleResponseFor(data.Responses, certificate, issuer)
+	if err != nil {
+		return OCSPResponse{}, err
+	}
+
+	out := OCSPResponse{
+		ProducedAt: data.ProducedAt,
+		ThisUpdate: single.ThisUpdate,
+		NextUpdate: single.NextUpdate,
+	}
+	if out.Responder, err = ocspResponderString(data.ResponderID); err != nil {
+		return OCSPResponse{}, err
+	}
+	switch single.CertStatus.Tag {
+	case 0:
+		out.Status = OCSPStatusGood
+	case 1:
+		var revoked ocspRevokedInfo
+		if _, err := asn1.UnmarshalWithParams(single.CertStatus.FullBytes, &revoked, "tag:1"); err != nil {
+			return OCSPResponse{}, fmt.Errorf("cannot parse OCSP revoked info: %w", err)
+		}
+		out.Status = OCSPStatusRevoked
+		out.RevokedAt = revoked.RevocationTime
+		if revoked.Reason != -1 {
+			out.RevocationReason = revocationReasonString(revoked.Reason)
+		}
+	default:
+		out.Status = OCSPStatusUnknown
+	}
+	out.SignatureError = verifyOCSPSignature(basic, data.ResponderID, data.ProducedAt, issuer)
+	return out, nil
+}
+
+// ocspSingleResponseFor returns response for the certificate serial number, issuer hashes are checked if issuer is set
+func ocspSingleResponseFor(responses []ocspSingleResponse, certificate, issuer *x509.Certificate) (ocspSingleResponse, error) {
+
+	for _, response := range responses {
+		if response.CertID.SerialNumber == nil || response.CertID.SerialNumber.Cmp(certificate.SerialNumber) != 0 {
+			continue
+		}
+		if issuer != nil {
+			nameHash, keyHash, err := issuerHashes(issuer, response.CertID.HashAlgorithm.Algorithm)
+			if err != nil {
+				return ocspSingleResponse{}, err
+			}
+			if !bytes.Equal(nameHash, response.CertID.IssuerNameHash) || !bytes.Equal(keyHash, response.CertID.IssuerKeyHash) {
+				continue
+			}
+		}
+		return response, nil
+	}
+	return ocspSingleResponse{}, fmt.Errorf("OCSP response does not contain certificate serial number %s", formatHexArray(certificate.SerialNumber.Bytes()))
+}
+
+// issuerHashes returns hash of issuer subject and public key (without tag, length and unused bits) used in OCSP CertID
+func issuerHashes(issuer *x509.Certificate, algorithm asn1.ObjectIdentifier) ([]byte, []byte, error) {
+
+	hash, ok := ocspHashAlgorithms[algorithm.String()]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported OCSP hash algorithm %s", algorithm)
+	}
+	publicKey, err := subjectPublicKeyBits(issuer)
+	if err != nil {
+		return nil, nil, err
+	}
+	nameHash := hash.New()
+	nameHash.Write(issuer.RawSubject)
+	keyHash := hash.New()
+	keyHash.Write(publicKey)
+	return nameHash.Sum(nil), keyHash.Sum(nil), nil
+}
+
+// SubjectPublicKeyInfo ::= SEQUENCE {
+// algorithm        AlgorithmIdentifier,
+// subjectPublicKey BIT STRING }
+func subjectPublicKeyBits(certificate *x509.Certificate) ([]byte, error) {
+
+	var info struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(certificate.RawSubjectPublicKeyInfo, &info); err != nil {
+		return nil, fmt.Errorf("cannot parse subject public key info: %w", err)
+	}
+	return info.PublicKey.RightAlign(), nil
+}
+
+func ocspResponderString(responderID asn1.RawValue) (string, error) {
+
+	switch responderID.Tag {
+	case 1:
+		var name pkix.RDNSequence
+		if _, err := asn1.Unmarshal(responderID.Bytes, &name); err != nil {
+			return "", fmt.Errorf("cannot parse OCSP responder name: %w", err)
+		}
+		var n pkix.Name
+		n.FillFromRDNSequence(&name)
+		return n.String(), nil
+	case 2:
+		var keyHash []byte
+		if _, err := asn1.Unmarshal(responderID.Bytes, &keyHash); err != nil {
+			return "", fmt.Errorf("cannot parse OCSP responder key hash: %w", err)
+		}
+		return "key hash " + formatHexArray(keyHash), nil
+	default:
+		return "", fmt.Errorf("unsupported OCSP responder id [%d]", responderID.Tag)
+	}
+}
+
+// verifyOCSPSignature verifies response signature by the issuer, or by delegated responder certificate from the
+// response that is issued by the issuer for OCSP signing and is valid when the response was produced
+func verifyOCSPSignature(basic ocspBasicResponse, responderID asn1.RawValue, producedAt time.Time, issuer *x509.Certificate) error {
+
+	if issuer == nil {
+		return errors.New("issuer certificate not found")
+	}
+	algorithm, ok := ocspSignatureAlgorithms[basic.SignatureAlgorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("unsupported signature algorithm %s", basic.SignatureAlgorithm.Algorithm)
+	}
+
+	signer := issuer
+	if !isOCSPResponder(responderID, issuer) {
+		delegated, err := delegatedOCSPResponder(basic.Certificates, responderID)
+		if err != nil {
+			return err
+		}
+		if err := delegated.CheckSignatureFrom(issuer); err != nil {
+			return fmt.Errorf("delegated responder certificate is not issued by the issuer: %w", err)
+		}
+		if !slices.Contains(delegated.ExtKeyUsage, x509.ExtKeyUsageOCSPSigning) {
+			return errors.New("delegated responder certificate is not authorized for OCSP signing")
+		}
+		if producedAt.Before(delegated.NotBefore) || producedAt.After(delegated.NotAfter) {
+			return fmt.Errorf("delegated responder certificate is not valid at %s", producedAt.UTC().Format(time.RFC3339))
+		}
+		signer = delegated
+	}
+	if err := signer.CheckSignature(algorithm, basic.TBSResponseData.FullBytes, basic.Signature.RightAlign()); err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	return nil
+}
+
+// isOCSPResponder checks if the responder id identifies the certificate by name or by SHA-1 hash of the public key
+func isOCSPResponder(responderID asn1.RawValue, certificate *x509.Certificate) bool {
+
+	switch responderID.Tag {
+	case 1:
+		return bytes.Equal(responderID.Bytes, certificate.RawSubject)
+	case 2:
+		var keyHash []byte
+		if _, err := asn1.Unmarshal(responderID.Bytes, &keyHash); err != nil {
+			return false
+		}
+		publicKey, err := subjectPublicKeyBits(certificate)
+		if err != nil {
+			return false
+		}
+		hash := sha1.Sum(publicKey)
+		return bytes.Equal(keyHash, hash[:])
+	default:
+		return false
+	}
+}
+
+func delegatedOCSPResponder(certificates []asn1.RawValue, responderID asn1.RawValue) (*x509.Certificate, error) {
+
+	for _, raw := range certificates {
+		certificate, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse responder certificate: %w", err)
+		}
+		if isOCSPResponder(responderID, certificate) {
+			return certificate, nil
+		}
+	}
+	return nil, errors.New("responder certificate not found")
+}
+
+func revocationReasonString(reason asn1.Enumerated) string {
+
+	if s, ok := revocationReasons[reason]; ok {
+		return s
+	}
+	return fmt.Sprintf("reason %d", reason)
+}
+
+// stapledOCSPResponse decodes OCSP response stapled in TLS handshake for the leaf certificate, signature is verified by
+// the issuer from the served chain, nil if the server did not staple OCSP response
+func stapledOCSPResponse(state tls.ConnectionState) (*OCSPResponse, error) {
+
+	if len(state.OCSPResponse) == 0 || len(state.PeerCertificates) == 0 {
+		return nil, nil
+	}
+	leaf := state.PeerCertificates[0]
+	response, err := ParseOCSPResponse(state.OCSPResponse, leaf, ocspIssuer(leaf, state.PeerCertificates[1:]))
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// ocspIssuer returns issuer of the certificate from the certificates (e.g. served chain), nil if it is not found
+func ocspIssuer(certificate *x509.Certificate, certificates []*x509.Certificate) *x509.Certificate {
+
+	for _, candidate := range certificates {
+		if !bytes.Equal(candidate.RawSubject, certificate.RawIssuer) {
+			continue
+		}
+		if certificate.CheckSignatureFrom(candidate) == nil {
+			return candidate
+		}
+	}
+	return nil
+}
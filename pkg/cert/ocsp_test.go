@@ -0,0 +1,255 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOCSPResponse(t *testing.T) {
+	pki := newTestPKI(t)
+	leaf := pki.leaf.Leaf
+
+	t.Run("given good response signed by issuer then response is decoded and signature is valid", func(t *testing.T) {
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: pki.intermediate, signerKey: pki.intermediateKey})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.Equal(t, OCSPStatusGood, response.Status)
+		assert.Equal(t, "CN=certinfo test intermediate", response.Responder)
+		assert.False(t, response.ThisUpdate.IsZero())
+		assert.False(t, response.NextUpdate.IsZero())
+		assert.False(t, response.IsExpired())
+		assert.NoError(t, response.SignatureError)
+	})
+
+	t.Run("given revoked response then revocation time and reason are returned", func(t *testing.T) {
+		revokedAt := time.Now().Add(-time.Minute).UTC().Truncate(time.Second)
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: pki.intermediate,
+			signerKey: pki.intermediateKey, revokedAt: revokedAt, reason: 1})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.Equal(t, OCSPStatusRevoked, response.Status)
+		assert.Equal(t, revokedAt, response.RevokedAt)
+		assert.Equal(t, "key compromise", response.RevocationReason)
+		assert.NoError(t, response.SignatureError)
+	})
+
+	t.Run("given response signed by delegated responder then signature is valid", func(t *testing.T) {
+		responder, responderKey := testOCSPResponder(t, pki, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, time.Now().Add(time.Hour))
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: responder,
+			signerKey: responderKey, byKey: true, certificates: []*x509.Certificate{responder}})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.Contains(t, response.Responder, "key hash ")
+		assert.NoError(t, response.SignatureError)
+	})
+
+	t.Run("given delegated responder without ocsp signing usage then signature error is set", func(t *testing.T) {
+		responder, responderKey := testOCSPResponder(t, pki, nil, time.Now().Add(time.Hour))
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: responder,
+			signerKey: responderKey, certificates: []*x509.Certificate{responder}})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.EqualError(t, response.SignatureError, "delegated responder certificate is not authorized for OCSP signing")
+	})
+
+	t.Run("given delegated responder certificate expired before response was produced then signature error is set", func(t *testing.T) {
+		responder, responderKey := testOCSPResponder(t, pki, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, time.Now().Add(-time.Hour))
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: responder,
+			signerKey: responderKey, certificates: []*x509.Certificate{responder}})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.ErrorContains(t, response.SignatureError, "delegated responder certificate is not valid at ")
+	})
+
+	t.Run("given response signed by other key then signature error is set", func(t *testing.T) {
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: pki.intermediate, signerKey: otherKey})
+		response, err := ParseOCSPResponse(der, leaf, pki.intermediate)
+		require.NoError(t, err)
+		assert.ErrorContains(t, response.SignatureError, "invalid signature")
+	})
+
+	t.Run("given response for other issuer then error is returned", func(t *testing.T) {
+		der := createTestOCSPResponse(t, testOCSPResponse{certificate: leaf, issuer: pki.intermediate, signer: pki.intermediate, signerKey: pki.intermediateKey})
+		_, err := ParseOCSPResponse(der, leaf, pki.root)
+		assert.ErrorContains(t, err, "OCSP response does not contain certificate serial number")
+	})
+
+	t.Run("given unsuccessful response then error is returned", func(t *testing.T) {
+		der, err := asn1.Marshal(ocspResponse{Status: 6})
+		require.NoError(t, err)
+		_, err = ParseOCSPResponse(der, leaf, pki.intermediate)
+		assert.EqualError(t, err, "OCSP response status unauthorized")
+	})
+}
+
+func TestLoadCertificatesFromNetwork_ocspStaple(t *testing.T) {
+	pki := newTestPKI(t)
+
+	t.Run("given server staples ocsp response then response is decoded and verified by served issuer", func(t *testing.T) {
+		certificate := pki.leaf
+		certificate.Certificate = [][]byte{pki.leaf.Leaf.Raw, pki.intermediate.Raw}
+		certificate.OCSPStaple = createTestOCSPResponse(t, testOCSPResponse{certificate: pki.leaf.Leaf, issuer: pki.intermediate,
+			signer: pki.intermediate, signerKey: pki.intermediateKey})
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}}).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.NoError(t, location.OCSPStapleError)
+		require.NotNil(t, location.OCSPStaple)
+		assert.Equal(t, OCSPStatusGood, location.OCSPStaple.Status)
+		assert.NoError(t, location.OCSPStaple.SignatureError)
+		assert.False(t, location.MissingStaple())
+	})
+
+	t.Run("given must-staple certificate without staple then missing staple is reported", func(t *testing.T) {
+		certificate := testMustStapleCertificate(t, pki)
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{certificate}}).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Nil(t, location.OCSPStaple)
+		assert.True(t, location.Certificates[0].MustStaple())
+		assert.True(t, location.MissingStaple())
+	})
+
+	t.Run("given certificate without must-staple and without staple then missing staple is not reported", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{pki.leaf}}).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Nil(t, location.OCSPStaple)
+		assert.False(t, location.MissingStaple())
+	})
+}
+
+type testOCSPResponse struct {
+	certificate  *x509.Certificate
+	issuer       *x509.Certificate
+	signer       *x509.Certificate
+	signerKey    *ecdsa.PrivateKey
+	byKey        bool
+	certificates []*x509.Certificate
+	// revokedAt is set for revoked response
+	revokedAt time.Time
+	reason    int
+}
+
+// createTestOCSPResponse returns DER OCSP response for the certificate, responder id is the signer
+func createTestOCSPResponse(t *testing.T, r testOCSPResponse) []byte {
+	nameHash, keyHash, err := issuerHashes(r.issuer, asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26})
+	require.NoError(t, err)
+
+	status := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0}
+	if !r.revokedAt.IsZero() {
+		revoked, err := asn1.Marshal(struct {
+			RevocationTime time.Time       `asn1:"generalized"`
+			Reason         asn1.Enumerated `asn1:"explicit,tag:0"`
+		}{r.revokedAt, asn1.Enumerated(r.reason)})
+		require.NoError(t, err)
+		var sequence asn1.RawValue
+		_, err = asn1.Unmarshal(revoked, &sequence)
+		require.NoError(t, err)
+		status = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: sequence.Bytes}
+	}
+
+	responderID := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: r.signer.RawSubject}
+	if r.byKey {
+		publicKey, err := subjectPublicKeyBits(r.signer)
+		require.NoError(t, err)
+		hash := sha1.Sum(publicKey)
+		b, err := asn1.Marshal(hash[:])
+		require.NoError(t, err)
+		responderID = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 2, IsCompound: true, Bytes: b}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	tbs, err := asn1.Marshal(ocspResponseData{
+		ResponderID: responderID,
+		ProducedAt:  now,
+		Responses: []ocspSingleResponse{{
+			CertID: ocspCertID{
+				HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}, Parameters: asn1.NullRawValue},
+				IssuerNameHash: nameHash,
+				IssuerKeyHash:  keyHash,
+				SerialNumber:   r.certificate.SerialNumber,
+			},
+			CertStatus: status,
+			ThisUpdate: now.Add(-time.Minute),
+			NextUpdate: now.Add(time.Hour),
+		}},
+	})
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(tbs)
+	signature, err := ecdsa.SignASN1(rand.Reader, r.signerKey, digest[:])
+	require.NoError(t, err)
+	var certificates []asn1.RawValue
+	for _, certificate := range r.certificates {
+		certificates = append(certificates, asn1.RawValue{FullBytes: certificate.Raw})
+	}
+	basic, err := asn1.Marshal(ocspBasicResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)},
+		Certificates:       certificates,
+	})
+	require.NoError(t, err)
+
+	der, err := asn1.Marshal(ocspResponse{ResponseBytes: ocspResponseBytes{ResponseType: oidOCSPBasicResponse, Response: basic}})
+	require.NoError(t, err)
+	return der
+}
+
+// testOCSPResponder returns delegated responder certificate issued by the test intermediate
+func testOCSPResponder(t *testing.T, pki testPKI, extKeyUsage []x509.ExtKeyUsage, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	responder := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(4),
+		Subject:      pkix.Name{CommonName: "certinfo test ocsp responder"},
+		NotBefore:    notAfter.Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		ExtKeyUsage:  extKeyUsage,
+	}, pki.intermediate, key, pki.intermediateKey)
+	return responder, key
+}
+
+// testMustStapleCertificate returns leaf certificate with TLS feature status_request issued by the test intermediate
+func testMustStapleCertificate(t *testing.T, pki testPKI) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	feature, err := asn1.Marshal([]int{tlsFeatureStatusRequest})
+	require.NoError(t, err)
+	leaf := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:    big.NewInt(5),
+		Subject:         pkix.Name{CommonName: "certinfo.test"},
+		DNSNames:        []string{"certinfo.test"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}, Value: feature}},
+	}, pki.intermediate, key, pki.intermediateKey)
+	return tls.Certificate{Certificate: [][]byte{leaf.Raw, pki.intermediate.Raw}, PrivateKey: key, Leaf: leaf}
+}
@@ -0,0 +1,582 @@
+package cert
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"hash"
+	"log/slog"
+	"unicode/utf16"
+)
+
+var (
+	oidDataContentType          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+
+	oidKeyBag               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPKCS8ShroudedKeyBag  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidX509CertificateInBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+
+	oidPBEWithSHAAnd3KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd2KeyTripleDESCBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 4}
+	oidPBEWithSHAAnd128BitRC2CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40BitRC2CBC      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+	oidPBES2                         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2                        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+)
+
+var errIncorrectPassword = errors.New("pkcs12: decryption password incorrect")
+
+// digest algorithms used by PKCS#12 MAC
+var pkcs12DigestsByOid = map[string]func() hash.Hash{
+	"1.3.14.3.2.26":          sha1.New,
+	"2.16.840.1.101.3.4.2.1": sha256.New,
+	"2.16.840.1.101.3.4.2.2": sha512.New384,
+	"2.16.840.1.101.3.4.2.3": sha512.New,
+	"2.16.840.1.101.3.4.2.4": sha256.New224,
+}
+
+// PBKDF2 pseudo random functions
+var pbkdf2PRFsByOid = map[string]func() hash.Hash{
+	"1.2.840.113549.2.7":  sha1.New,
+	"1.2.840.113549.2.8":  sha256.New224,
+	"1.2.840.113549.2.9":  sha256.New,
+	"1.2.840.113549.2.10": sha512.New384,
+	"1.2.840.113549.2.11": sha512.New,
+}
+
+// PBES2 encryption schemes and their key sizes
+var pbes2CiphersByOid = map[string]struct {
+	keySize int
+	create  func(key []byte) (cipher.Block, error)
+}{
+	"2.16.840.1.101.3.4.1.2":  {keySize: 16, create: aes.NewCipher},
+	"2.16.840.1.101.3.4.1.22": {keySize: 24, create: aes.NewCipher},
+	"2.16.840.1.101.3.4.1.42": {keySize: 32, create: aes.NewCipher},
+	"1.2.840.113549.3.7":      {keySize: 24, create: des.NewTripleDESCipher},
+}
+
+// PrivateKey describes private key found in a keystore, key itself is never printed
+type PrivateKey struct {
+	Algorithm string
+	// whether the private key matches public key of the leaf certificate
+	MatchesLeaf bool
+}
+
+// PFX ::= SEQUENCE {
+// version     INTEGER {v3(3)}(v3,...),
+// authSafe    ContentInfo,
+// macData     MacData OPTIONAL }
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData `asn1:"optional"`
+}
+
+// ContentInfo ::= SEQUENCE {
+// contentType ContentType,
+// content     [0] EXPLICIT ANY DEFINED BY contentType OPTIONAL }
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+// MacData ::= SEQUENCE {
+// mac         DigestInfo,
+// macSalt     OCTET STRING,
+// iterations  INTEGER DEFAULT 1 }
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+// EncryptedData ::= SEQUENCE {
+// version              Version,
+// encryptedContentInfo EncryptedContentInfo }
+type encryptedData struct {
+	Version              int
+	EncryptedContentInfo encryptedContentInfo
+}
+
+type encryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           []byte `asn1:"tag:0,optional"`
+}
+
+// SafeBag ::= SEQUENCE {
+// bagId         BAG-TYPE.&id ({PKCS12BagSet})
+// bagValue      [0] EXPLICIT BAG-TYPE.&Type({PKCS12BagSet}{@bagId}),
+// bagAttributes SET OF PKCS12Attribute OPTIONAL }
+type safeBag struct {
+	Id         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue `asn1:"set"`
+}
+
+// CertBag ::= SEQUENCE {
+// certId    BAG-TYPE.&id   ({CertTypes}),
+// certValue [0] EXPLICIT BAG-TYPE.&Type ({CertTypes}{@certId}) }
+type certBag struct {
+	Id   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbeParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	Prf            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// isPKCS12 checks if the data is PKCS#12 (PFX) container
+func isPKCS12(data []byte) bool {
+
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return false
+	}
+	return pfx.Version == 3 && pfx.AuthSafe.ContentType.Equal(oidDataContentType)
+}
+
+func loadPKCS12(fileName string, data []byte, password string) CertificateLocation {
+
+	certificates, privateKeys, err := fromPKCS12(data, password)
+	if err != nil {
+		return CertificateLocation{Path: fileName, Error: err}
+	}
+
+	return CertificateLocation{
+		Path:         fileName,
+		Certificates: certificates,
+		PrivateKeys:  privateKeys,
+	}
+}
+
+// fromPKCS12 decrypts PKCS#12 container and returns all certificate bags and information about private key bags
+func fromPKCS12(data []byte, password string) (Certificates, []PrivateKey, error) {
+
+	certificates, keys, err := decodePKCS12(data, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var privateKeys []PrivateKey
+	for _, key := range keys {
+		privateKeys = append(privateKeys, toPrivateKey(key, leafCertificate(certificates)))
+	}
+	return certificates, privateKeys, nil
+}
+
+// decodePKCS12 decrypts PKCS#12 container and returns all certificate bags and private keys
+func decodePKCS12(data []byte, password string) (Certificates, []crypto.PrivateKey, error) {
+
+	bags, err := pkcs12SafeBags(data, password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var certificates Certificates
+	var keys []crypto.PrivateKey
+	for _, bag := range bags {
+		switch {
+		case bag.Id.Equal(oidCertBag):
+			var cb certBag
+			if _, err := asn1.Unmarshal(bag.Value.Bytes, &cb); err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: cert bag: %w", err)
+			}
+			position := len(certificates) + 1
+			if !cb.Id.Equal(oidX509CertificateInBag) {
+				certificates = append(certificates, Certificate{position: position, err: fmt.Errorf("unsupported cert bag type %s", cb.Id)})
+				continue
+			}
+			certificate := fromDERBlock(position, cb.Data)
+			certificate.alias = friendlyName(bag.Attributes)
+			certificates = append(certificates, certificate)
+		case bag.Id.Equal(oidKeyBag):
+			key, err := x509.ParsePKCS8PrivateKey(bag.Value.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: key bag: %w", err)
+			}
+			keys = append(keys, key)
+		case bag.Id.Equal(oidPKCS8ShroudedKeyBag):
+			var info encryptedPrivateKeyInfo
+			if _, err := asn1.Unmarshal(bag.Value.Bytes, &info); err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: shrouded key bag: %w", err)
+			}
+			decrypted, err := pbeDecrypt(info.Algorithm, info.EncryptedData, password)
+			if err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: shrouded key bag: %w", err)
+			}
+			key, err := x509.ParsePKCS8PrivateKey(decrypted)
+			if err != nil {
+				return nil, nil, fmt.Errorf("pkcs12: shrouded key bag: %w", err)
+			}
+			keys = append(keys, key)
+		default:
+			slog.Debug(fmt.Sprintf("pkcs12: skipping bag type %s", bag.Id))
+		}
+	}
+	return certificates, keys, nil
+}
+
+func pkcs12SafeBags(data []byte, password string) ([]safeBag, error) {
+
+	var pfx pfxPdu
+	if _, err := asn1.Unmarshal(data, &pfx); err != nil {
+		return nil, fmt.Errorf("pkcs12: %w", err)
+	}
+	if pfx.Version != 3 {
+		return nil, fmt.Errorf("pkcs12: unsupported version %d", pfx.Version)
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, errors.New("pkcs12: only password integrity mode is supported")
+	}
+
+	var authSafe []byte
+	if _, err := asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafe); err != nil {
+		return nil, fmt.Errorf("pkcs12: auth safe: %w", err)
+	}
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) != 0 {
+		if err := verifyPKCS12Mac(pfx.MacData, authSafe, password); err != nil {
+			return nil, err
+		}
+	}
+
+	var contentInfos []contentInfo
+	if _, err := asn1.Unmarshal(authSafe, &contentInfos); err != nil {
+		return nil, fmt.Errorf("pkcs12: authenticated safe: %w", err)
+	}
+
+	var bags []safeBag
+	for _, ci := range contentInfos {
+		var safeContents []byte
+		switch {
+		case ci.ContentType.Equal(oidDataContentType):
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &safeContents); err != nil {
+				return nil, fmt.Errorf("pkcs12: data: %w", err)
+			}
+		case ci.ContentType.Equal(oidEncryptedDataContentType):
+			var ed encryptedData
+			if _, err := asn1.Unmarshal(ci.Content.Bytes, &ed); err != nil {
+				return nil, fmt.Errorf("pkcs12: encrypted data: %w", err)
+			}
+			info := ed.EncryptedContentInfo
+			decrypted, err := pbeDecrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent, password)
+			if err != nil {
+				return nil, fmt.Errorf("pkcs12: encrypted data: %w", err)
+			}
+			safeContents = decrypted
+		default:
+			return nil, fmt.Errorf("pkcs12: unsupported content type %s", ci.ContentType)
+		}
+
+		var contentBags []safeBag
+		if _, err := asn1.Unmarshal(safeContents, &contentBags); err != nil {
+			return nil, fmt.Errorf("pkcs12: safe contents: %w", err)
+		}
+		bags = append(bags, contentBags...)
+	}
+	return bags, nil
+}
+
+func verifyPKCS12Mac(mac macData, message []byte, password string) error {
+
+	newHash, ok := pkcs12DigestsByOid[mac.Mac.Algorithm.Algorithm.String()]
+	if !ok {
+		return fmt.Errorf("pkcs12: unsupported MAC algorithm %s", mac.Mac.Algorithm.Algorithm)
+	}
+
+	expected := func(password []byte) []byte {
+		key := pkcs12KDF(newHash, 3, password, mac.MacSalt, mac.Iterations, newHash().Size())
+		h := hmac.New(newHash, key)
+		h.Write(message)
+		return h.Sum(nil)
+	}
+
+	if hmac.Equal(mac.Mac.Digest, expected(bmpPassword(password))) {
+		return nil
+	}
+	// some implementations use empty byte array instead of two zero bytes for empty password
+	if password == "" && hmac.Equal(mac.Mac.Digest, expected(nil)) {
+		return nil
+	}
+	return errIncorrectPassword
+}
+
+// friendlyName returns friendly name bag attribute (alias), or empty string if the attribute is not set
+func friendlyName(attributes []pkcs12Attribute) string {
+
+	for _, attribute := range attributes {
+		if !attribute.Id.Equal(oidFriendlyName) {
+			continue
+		}
+		var name asn1.RawValue
+		if _, err := asn1.Unmarshal(attribute.Value.Bytes, &name); err != nil || len(name.Bytes)%2 != 0 {
+			return ""
+		}
+		// BMPString, UTF-16 big endian
+		var chars []uint16
+		for i := 0; i < len(name.Bytes); i += 2 {
+			chars = append(chars, uint16(name.Bytes[i])<<8|uint16(name.Bytes[i+1]))
+		}
+		return string(utf16.Decode(chars))
+	}
+	return ""
+}
+
+// pbeDecrypt decrypts data with password based encryption scheme, either PKCS#12 (RFC 7292 appendix C) or PBES2
+func pbeDecrypt(algorithm pkix.AlgorithmIdentifier, encrypted []byte, password string) ([]byte, error) {
+
+	var block cipher.Block
+	var iv []byte
+	switch {
+	case algorithm.Algorithm.Equal(oidPBES2):
+		b, v, err := pbes2Cipher(algorithm, password)
+		if err != nil {
+			return nil, err
+		}
+		block, iv = b, v
+	default:
+		b, v, err := pkcs12PBECipher(algorithm, password)
+		if err != nil {
+			return nil, err
+		}
+		block, iv = b, v
+	}
+
+	if len(encrypted) == 0 || len(encrypted)%block.BlockSize() != 0 {
+		return nil, errors.New("encrypted data is not a multiple of the block size")
+	}
+	decrypted := make([]byte, len(encrypted))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, encrypted)
+
+	// remove PKCS#7 padding
+	padding := int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, errIncorrectPassword
+	}
+	if !bytes.Equal(decrypted[len(decrypted)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errIncorrectPassword
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+func pkcs12PBECipher(algorithm pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+
+	var params pbeParams
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+
+	p := bmpPassword(password)
+	iv := pkcs12KDF(sha1.New, 2, p, params.Salt, params.Iterations, 8)
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDESCBC):
+		block, err := des.NewTripleDESCipher(pkcs12KDF(sha1.New, 1, p, params.Salt, params.Iterations, 24))
+		return block, iv, err
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd2KeyTripleDESCBC):
+		key := pkcs12KDF(sha1.New, 1, p, params.Salt, params.Iterations, 16)
+		block, err := des.NewTripleDESCipher(append(key, key[:8]...))
+		return block, iv, err
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC):
+		return newRC2Cipher(pkcs12KDF(sha1.New, 1, p, params.Salt, params.Iterations, 16), 128), iv, nil
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+		return newRC2Cipher(pkcs12KDF(sha1.New, 1, p, params.Salt, params.Iterations, 5), 40), iv, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported encryption algorithm %s", algorithm.Algorithm)
+	}
+}
+
+func pbes2Cipher(algorithm pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, nil, err
+	}
+
+	// default PRF is hmacWithSHA1
+	prf := sha1.New
+	if len(kdfParams.Prf.Algorithm) != 0 {
+		v, ok := pbkdf2PRFsByOid[kdfParams.Prf.Algorithm.String()]
+		if !ok {
+			return nil, nil, fmt.Errorf("unsupported PBKDF2 PRF %s", kdfParams.Prf.Algorithm)
+		}
+		prf = v
+	}
+
+	scheme, ok := pbes2CiphersByOid[params.EncryptionScheme.Algorithm.String()]
+	if !ok {
+		return nil, nil, fmt.Errorf("unsupported encryption scheme %s", params.EncryptionScheme.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, nil, err
+	}
+
+	key, err := pbkdf2.Key(prf, password, kdfParams.Salt, kdfParams.IterationCount, scheme.keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, err := scheme.create(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(iv) != block.BlockSize() {
+		return nil, nil, fmt.Errorf("invalid IV length %d", len(iv))
+	}
+	return block, iv, nil
+}
+
+// pkcs12KDF derives key material from password as described in RFC 7292 appendix B.2,
+// id is 1 for encryption key, 2 for IV and 3 for MAC key
+func pkcs12KDF(newHash func() hash.Hash, id byte, password, salt []byte, iterations, size int) []byte {
+
+	h := newHash()
+	v := h.BlockSize()
+
+	d := bytes.Repeat([]byte{id}, v)
+	i := append(fillBlocks(salt, v), fillBlocks(password, v)...)
+
+	var out []byte
+	for len(out) < size {
+		h.Reset()
+		h.Write(d)
+		h.Write(i)
+		a := h.Sum(nil)
+		for r := 1; r < iterations; r++ {
+			h.Reset()
+			h.Write(a)
+			a = h.Sum(nil)
+		}
+		out = append(out, a...)
+
+		// I_j = (I_j + B + 1) mod 2^v for each v-bit block of I, where B is A repeated to v bytes
+		b := fillBlocks(a, v)
+		for j := 0; j < len(i); j += v {
+			carry := 1
+			for k := v - 1; k >= 0; k-- {
+				sum := int(i[j+k]) + int(b[k]) + carry
+				i[j+k] = byte(sum)
+				carry = sum >> 8
+			}
+		}
+	}
+	return out[:size]
+}
+
+// fillBlocks repeats input to the nearest multiple of v bytes
+func fillBlocks(in []byte, v int) []byte {
+
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]byte, v*((len(in)+v-1)/v))
+	for i := range out {
+		out[i] = in[i%len(in)]
+	}
+	return out
+}
+
+// bmpPassword encodes password as null terminated BMPString (UTF-16 big endian)
+func bmpPassword(password string) []byte {
+
+	var out []byte
+	for _, v := range utf16.Encode([]rune(password)) {
+		out = append(out, byte(v>>8), byte(v))
+	}
+	return append(out, 0, 0)
+}
+
+// leafCertificate returns the first end-entity certificate, or first certificate if there is no end-entity
+func leafCertificate(certificates Certificates) *x509.Certificate {
+
+	var first *x509.Certificate
+	for _, c := range certificates {
+		if c.err != nil {
+			continue
+		}
+		if first == nil {
+			first = c.x509Certificate
+		}
+		if c.Type() == "end-entity" {
+			return c.x509Certificate
+		}
+	}
+	return first
+}
+
+func toPrivateKey(key crypto.PrivateKey, leaf *x509.Certificate) PrivateKey {
+
+	privateKey := PrivateKey{Algorithm: "unknown"}
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		privateKey.Algorithm = x509.RSA.String()
+	case *ecdsa.PrivateKey:
+		privateKey.Algorithm = x509.ECDSA.String()
+	case ed25519.PrivateKey:
+		privateKey.Algorithm = x509.Ed25519.String()
+	case *ecdh.PrivateKey:
+		privateKey.Algorithm = "ECDH"
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok || leaf == nil {
+		return privateKey
+	}
+	if publicKey, ok := signer.Public().(interface{ Equal(crypto.PublicKey) bool }); ok {
+		privateKey.MatchesLeaf = publicKey.Equal(leaf.PublicKey)
+	}
+	return privateKey
+}
@@ -0,0 +1,78 @@
+package cert
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_loadPKCS12(t *testing.T) {
+	t.Run("given PBES2 (AES) keystore and correct password then certificates and private key are loaded", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore.p12"), "test")
+		require.NoError(t, location.Error)
+		require.Equal(t, 2, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, "certinfo", location.Certificates[0].Alias())
+		assert.Equal(t, "CN=certinfo test CA", location.Certificates[1].SubjectString())
+		require.Equal(t, 1, len(location.PrivateKeys))
+		assert.Equal(t, "ECDSA", location.PrivateKeys[0].Algorithm)
+		assert.True(t, location.PrivateKeys[0].MatchesLeaf)
+	})
+
+	t.Run("given legacy (RC2, 3DES) keystore and correct password then certificates and private key are loaded", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore_legacy.p12"), "test")
+		require.NoError(t, location.Error)
+		require.Equal(t, 2, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		require.Equal(t, 1, len(location.PrivateKeys))
+		assert.True(t, location.PrivateKeys[0].MatchesLeaf)
+	})
+
+	t.Run("given keystore without private key then only certificates are loaded", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore_no_key.p12"), "test")
+		require.NoError(t, location.Error)
+		require.Equal(t, 2, len(location.Certificates))
+		assert.Empty(t, location.PrivateKeys)
+	})
+
+	t.Run("given incorrect password then error is returned", func(t *testing.T) {
+		location := loadCertificate("test", loadTestFile(t, "keystore.p12"), "incorrect")
+		assert.ErrorIs(t, location.Error, errIncorrectPassword)
+		assert.Empty(t, location.Certificates)
+	})
+
+	t.Run("given PEM certificate then it is not identified as PKCS#12", func(t *testing.T) {
+		assert.False(t, isPKCS12(loadTestFile(t, "cert.pem")))
+		assert.False(t, isPKCS12(loadTestFile(t, "cert.der")))
+	})
+}
+
+func Test_rc2Cipher(t *testing.T) {
+	// test vectors from RFC 2268 section 5
+	vectors := []struct {
+		key, plain, cipher string
+		effectiveBits      int
+	}{
+		{key: "0000000000000000", plain: "0000000000000000", cipher: "ebb773f993278eff", effectiveBits: 63},
+		{key: "ffffffffffffffff", plain: "ffffffffffffffff", cipher: "278b27e42e2f0d49", effectiveBits: 64},
+		{key: "3000000000000000", plain: "1000000000000001", cipher: "30649edf9be7d2c2", effectiveBits: 64},
+		{key: "88bca90e90875a7f0f79c384627bafb2", plain: "0000000000000000", cipher: "2269552ab0f85ca6", effectiveBits: 128},
+	}
+
+	for _, v := range vectors {
+		block := newRC2Cipher(mustDecodeHex(t, v.key), v.effectiveBits)
+		out := make([]byte, rc2BlockSize)
+		block.Encrypt(out, mustDecodeHex(t, v.plain))
+		assert.Equal(t, v.cipher, hex.EncodeToString(out))
+		block.Decrypt(out, out)
+		assert.Equal(t, v.plain, hex.EncodeToString(out))
+	}
+}
+
+func mustDecodeHex(t *testing.T, in string) []byte {
+	b, err := hex.DecodeString(in)
+	require.NoError(t, err)
+	return b
+}
@@ -0,0 +1,97 @@
+package cert
+
+import (
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+const (
+	pkcs7BlockType = "PKCS7"
+	cmsBlockType   = "CMS"
+)
+
+var oidSignedDataContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+
+// SignedData ::= SEQUENCE {
+// version          CMSVersion,
+// digestAlgorithms DigestAlgorithmIdentifiers,
+// encapContentInfo EncapsulatedContentInfo,
+// certificates     [0] IMPLICIT CertificateSet OPTIONAL,
+// crls             [1] IMPLICIT RevocationInfoChoices OPTIONAL,
+// signerInfos      SignerInfos }
+type signedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      asn1.RawValue
+	Certificates     asn1.RawValue `asn1:"tag:0,optional"`
+	CRLs             asn1.RawValue `asn1:"tag:1,optional"`
+	SignerInfos      asn1.RawValue
+}
+
+// isPKCS7 checks if the data is PKCS#7 (CMS) SignedData content info, e.g. .p7b or .p7c file
+func isPKCS7(data []byte) bool {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return false
+	}
+	return ci.ContentType.Equal(oidSignedDataContentType)
+}
+
+// fromPKCS7 returns certificates from SignedData certificates set, position is the position of the first certificate
+func fromPKCS7(position int, data []byte) Certificates {
+
+	rawCertificates, err := pkcs7Certificates(data)
+	if err != nil {
+		return Certificates{{position: position, err: err}}
+	}
+
+	var certificates Certificates
+	for i, raw := range rawCertificates {
+		// CertificateChoices ::= CHOICE {
+		// certificate          Certificate,
+		// extendedCertificate  [0] IMPLICIT ExtendedCertificate, -- Obsolete
+		// v1AttrCert           [1] IMPLICIT AttributeCertificateV1, -- Obsolete
+		// v2AttrCert           [2] IMPLICIT AttributeCertificateV2,
+		// other                [3] IMPLICIT OtherCertificateFormat }
+		if raw.Class != asn1.ClassUniversal || raw.Tag != asn1.TagSequence {
+			certificates = append(certificates, Certificate{position: position + i, err: fmt.Errorf("unsupported PKCS7 certificate choice [%d]", raw.Tag)})
+			continue
+		}
+		certificates = append(certificates, fromDERBlock(position+i, raw.FullBytes))
+	}
+	return certificates
+}
+
+func pkcs7Certificates(data []byte) ([]asn1.RawValue, error) {
+
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(data, &ci); err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS7 content info: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedDataContentType) {
+		return nil, fmt.Errorf("unsupported PKCS7 content type %s", ci.ContentType)
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("cannot parse PKCS7 signed data: %w", err)
+	}
+
+	var certificates []asn1.RawValue
+	in := sd.Certificates.Bytes
+	for len(in) != 0 {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(in, &raw)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse PKCS7 certificates: %w", err)
+		}
+		certificates = append(certificates, raw)
+		in = rest
+	}
+	if len(certificates) == 0 {
+		return nil, errors.New("no certificates in PKCS7 signed data")
+	}
+	return certificates, nil
+}
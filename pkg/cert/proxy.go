@@ -0,0 +1,337 @@
+package cert
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProxyNone disables proxy, including proxy set by environment variables
+const ProxyNone = "none"
+
+var socks5Replies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+// proxyURL returns proxy for the address, proxy set by options is used for every address, otherwise HTTPS_PROXY
+// environment variable is used unless the address is localhost or matches NO_PROXY. Nil is returned if the connection
+// is direct.
+func (n NetworkOptions) proxyURL(addr string) (*url.URL, error) {
+
+	if n.Proxy == ProxyNone {
+		return nil, nil
+	}
+	if n.Proxy != "" {
+		return parseProxy(n.Proxy)
+	}
+	proxy := getEnvAny("HTTPS_PROXY", "https_proxy")
+	if proxy == "" || isLocalhost(addr) || matchesNoProxy(addr, getEnvAny("NO_PROXY", "no_proxy")) {
+		return nil, nil
+	}
+	return parseProxy(proxy)
+}
+
+func isLocalhost(addr string) bool {
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseProxy parses http or socks5 proxy URL, proxy without scheme is http proxy e.g. proxy.example.com:3128
+func parseProxy(proxy string) (*url.URL, error) {
+
+	if !strings.Contains(proxy, "://") {
+		proxy = "http://" + proxy
+	}
+	u, err := url.Parse(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy: %w", err)
+	}
+	var defaultPort string
+	switch u.Scheme {
+	case "http":
+		defaultPort = "80"
+	case "socks5", "socks5h":
+		defaultPort = "1080"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid proxy %s: missing host", u.Redacted())
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return u, nil
+}
+
+// proxyName returns proxy scheme and address, credentials are not included
+func proxyName(proxy *url.URL) string {
+
+	if proxy == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s://%s", proxy.Scheme, proxy.Host)
+}
+
+func getEnvAny(names ...string) string {
+
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// matchesNoProxy checks if the address matches comma separated NO_PROXY list, entries are host names (matching
+// subdomains as well, leading dot is optional), IP addresses, CIDR ranges, optionally with port, or "*" for every host
+func matchesNoProxy(addr, noProxy string) bool {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	ip := net.ParseIP(host)
+
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, ipNet, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && ipNet.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost, entryPort, err := net.SplitHostPort(entry)
+		if err != nil {
+			entryHost, entryPort = strings.TrimSuffix(strings.TrimPrefix(entry, "["), "]"), ""
+		}
+		if entryPort != "" && entryPort != port {
+			continue
+		}
+		if entryIP := net.ParseIP(entryHost); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entryHost = strings.TrimSuffix(strings.TrimPrefix(entryHost, "."), ".")
+		if host == entryHost || strings.HasSuffix(host, "."+entryHost) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyTargetAddr returns address sent to the proxy, host name is resolved locally for socks5 proxy and by the proxy for
+// socks5h and http proxy (curl convention), the first resolved IP address is used
+func proxyTargetAddr(ctx context.Context, proxy *url.URL, addr string, timeout time.Duration) (string, error) {
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if proxy.Scheme != "socks5" || net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", host)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// proxyTunnel opens tunnel to the address through already connected proxy connection
+func proxyTunnel(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+
+	if proxy.Scheme == "http" {
+		return httpConnect(conn, proxy, addr)
+	}
+	return conn, socks5Connect(conn, proxy, addr)
+}
+
+// httpConnect sends HTTP CONNECT request, returned connection includes data buffered after the proxy response
+func httpConnect(conn net.Conn, proxy *url.URL, addr string) (net.Conn, error) {
+
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxy.User != nil {
+		password, _ := proxy.User.Password()
+		req.SetBasicAuth(proxy.User.Username(), password)
+		req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+		req.Header.Del("Authorization")
+	}
+	if err := req.Write(conn); err != nil {
+		return nil, fmt.Errorf("proxy connect: %w", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		return nil, fmt.Errorf("proxy connect: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("proxy connect: %s", resp.Status)
+	}
+	// server can send data first (e.g. SMTP greeting) and it can be already read with the response
+	if r.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: r}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// socks5Connect negotiates SOCKS5 (RFC 1928) connection to the address, host name (if it was not resolved locally) is
+// resolved by the proxy, username and password authentication (RFC 1929) is used if the proxy URL has credentials
+func socks5Connect(conn net.Conn, proxy *url.URL, addr string) error {
+
+	host, portString, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return fmt.Errorf("invalid port %s", portString)
+	}
+
+	// version 5, no authentication or username and password
+	methods := []byte{0x00}
+	if proxy.User != nil {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if reply[0] != 0x05 {
+		return fmt.Errorf("socks5: unexpected version %d", reply[0])
+	}
+	switch reply[1] {
+	case 0x00:
+	case 0x02:
+		if err := socks5Authenticate(conn, proxy.User); err != nil {
+			return err
+		}
+	default:
+		return errors.New("socks5: no acceptable authentication method")
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("socks5: host %s too long", host)
+		}
+		request = append(append(request, 0x03, byte(len(host))), host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(append(request, 0x01), ip4...)
+	} else {
+		request = append(append(request, 0x04), ip.To16()...)
+	}
+	request = binary.BigEndian.AppendUint16(request, uint16(port))
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+
+	// version, reply, reserved and bound address type
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if header[1] != 0x00 {
+		if reason, ok := socks5Replies[header[1]]; ok {
+			return fmt.Errorf("socks5: %s", reason)
+		}
+		return fmt.Errorf("socks5: connect failed with reply %d", header[1])
+	}
+	var boundAddrSize int
+	switch header[3] {
+	case 0x01:
+		boundAddrSize = net.IPv4len
+	case 0x04:
+		boundAddrSize = net.IPv6len
+	case 0x03:
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return fmt.Errorf("socks5: %w", err)
+		}
+		boundAddrSize = int(size[0])
+	default:
+		return fmt.Errorf("socks5: unexpected address type %d", header[3])
+	}
+	// bound address and port are not used
+	if _, err := io.ReadFull(conn, make([]byte, boundAddrSize+2)); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	return nil
+}
+
+func socks5Authenticate(conn net.Conn, user *url.Userinfo) error {
+
+	username := user.Username()
+	password, _ := user.Password()
+	if len(username) > 255 || len(password) > 255 {
+		return errors.New("socks5: username or password too long")
+	}
+	request := append([]byte{0x01, byte(len(username))}, username...)
+	request = append(append(request, byte(len(password))), password...)
+	if _, err := conn.Write(request); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("socks5: %w", err)
+	}
+	if reply[1] != 0x00 {
+		return errors.New("socks5: authentication failed")
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package cert
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_proxy(t *testing.T) {
+	t.Run("given http proxy then certificates are loaded through connect tunnel", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		proxyAddr := startTestServer(t, fakeHTTPProxy(""))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Proxy: "http://" + proxyAddr, InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, "http://"+proxyAddr, location.Proxy)
+	})
+
+	t.Run("given http proxy with basic auth then credentials are sent and not shown in location", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		proxyAddr := startTestServer(t, fakeHTTPProxy("Basic dXNlcjpzZWNyZXQ="))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Proxy: "http://user:secret@" + proxyAddr, InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Equal(t, "http://"+proxyAddr, location.Proxy)
+	})
+
+	t.Run("given http proxy rejects credentials then error is returned", func(t *testing.T) {
+		proxyAddr := startTestServer(t, fakeHTTPProxy("Basic dXNlcjpzZWNyZXQ="))
+		location := LoadCertificatesFromNetwork(t.Context(), "127.0.0.1:443", NetworkOptions{Proxy: "http://user:wrong@" + proxyAddr})
+		assert.ErrorContains(t, location.Error, "407 Proxy Authentication Required")
+		assert.Equal(t, "http://"+proxyAddr, location.Proxy)
+	})
+
+	t.Run("given socks5 proxy then certificates are loaded through proxy", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		proxyAddr := startTestServer(t, fakeSOCKS5Proxy(t, "", "", nil))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Proxy: "socks5://" + proxyAddr, InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, "socks5://"+proxyAddr, location.Proxy)
+	})
+
+	t.Run("given socks5 proxy then host name is resolved locally", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+		hosts := make(chan string, 1)
+		proxyAddr := startTestServer(t, fakeSOCKS5Proxy(t, "", "", hosts))
+		location := LoadCertificatesFromNetwork(t.Context(), net.JoinHostPort("localhost", port), NetworkOptions{Proxy: "socks5://" + proxyAddr, InsecureSkipVerify: true})
+		assert.NotNil(t, net.ParseIP(<-hosts))
+		assert.Equal(t, "socks5://"+proxyAddr, location.Proxy)
+	})
+
+	t.Run("given socks5h proxy then host name is resolved by proxy", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		_, port, err := net.SplitHostPort(addr)
+		require.NoError(t, err)
+		hosts := make(chan string, 1)
+		proxyAddr := startTestServer(t, fakeSOCKS5Proxy(t, "", "", hosts))
+		location := LoadCertificatesFromNetwork(t.Context(), net.JoinHostPort("localhost", port), NetworkOptions{Proxy: "socks5h://" + proxyAddr, InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Equal(t, "localhost", <-hosts)
+	})
+
+	t.Run("given socks5 proxy with authentication then certificates are loaded through proxy", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+		proxyAddr := startTestServer(t, fakeSOCKS5Proxy(t, "user", "secret", nil))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Proxy: "socks5://user:secret@" + proxyAddr, InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+	})
+
+	t.Run("given socks5 proxy and wrong password then error is returned", func(t *testing.T) {
+		proxyAddr := startTestServer(t, fakeSOCKS5Proxy(t, "user", "secret", nil))
+		location := LoadCertificatesFromNetwork(t.Context(), "127.0.0.1:443", NetworkOptions{Proxy: "socks5://user:wrong@" + proxyAddr})
+		assert.ErrorContains(t, location.Error, "socks5: authentication failed")
+	})
+}
+
+func TestNetworkOptions_proxyURL(t *testing.T) {
+	t.Run("given proxy option then it is used for every address", func(t *testing.T) {
+		t.Setenv("NO_PROXY", "*")
+		proxy, err := NetworkOptions{Proxy: "socks5://proxy.example.com"}.proxyURL("example.com:443")
+		require.NoError(t, err)
+		assert.Equal(t, "socks5://proxy.example.com:1080", proxyName(proxy))
+	})
+
+	t.Run("given none proxy option then environment is not used", func(t *testing.T) {
+		t.Setenv("HTTPS_PROXY", "proxy.example.com:3128")
+		proxy, err := NetworkOptions{Proxy: ProxyNone}.proxyURL("example.com:443")
+		require.NoError(t, err)
+		assert.Nil(t, proxy)
+	})
+
+	t.Run("given address in no proxy then environment proxy is not used", func(t *testing.T) {
+		t.Setenv("HTTPS_PROXY", "proxy.example.com:3128")
+		t.Setenv("NO_PROXY", "internal.example.com,10.0.0.0/8")
+		proxy, err := NetworkOptions{}.proxyURL("api.internal.example.com:443")
+		require.NoError(t, err)
+		assert.Nil(t, proxy)
+
+		proxy, err = NetworkOptions{}.proxyURL("example.com:443")
+		require.NoError(t, err)
+		assert.Equal(t, "http://proxy.example.com:3128", proxyName(proxy))
+	})
+
+	t.Run("given localhost address then environment proxy is not used", func(t *testing.T) {
+		t.Setenv("HTTPS_PROXY", "proxy.example.com:3128")
+		t.Setenv("NO_PROXY", "")
+		for _, addr := range []string{"localhost:443", "127.0.0.1:443", "[::1]:443"} {
+			proxy, err := NetworkOptions{}.proxyURL(addr)
+			require.NoError(t, err)
+			assert.Nil(t, proxy, addr)
+		}
+	})
+
+	t.Run("given unsupported proxy scheme then error is returned", func(t *testing.T) {
+		_, err := NetworkOptions{Proxy: "ftp://proxy.example.com"}.proxyURL("example.com:443")
+		assert.EqualError(t, err, "unsupported proxy scheme ftp")
+	})
+}
+
+func Test_matchesNoProxy(t *testing.T) {
+	tests := []struct {
+		addr    string
+		noProxy string
+		matches bool
+	}{
+		{addr: "example.com:443", noProxy: "", matches: false},
+		{addr: "example.com:443", noProxy: "*", matches: true},
+		{addr: "example.com:443", noProxy: "example.com", matches: true},
+		{addr: "api.example.com:443", noProxy: "example.com", matches: true},
+		{addr: "api.example.com:443", noProxy: ".example.com", matches: true},
+		{addr: "notexample.com:443", noProxy: "example.com", matches: false},
+		{addr: "example.com:443", noProxy: "example.com:8443", matches: false},
+		{addr: "example.com:8443", noProxy: "other.com, example.com:8443", matches: true},
+		{addr: "10.1.2.3:443", noProxy: "10.0.0.0/8", matches: true},
+		{addr: "11.1.2.3:443", noProxy: "10.0.0.0/8", matches: false},
+		{addr: "[2001:db8::1]:443", noProxy: "2001:db8::1", matches: true},
+		{addr: "[2001:db8::1]:443", noProxy: "[2001:db8::1]:443", matches: true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("given %s and no proxy %q then matches is %t", tt.addr, tt.noProxy, tt.matches), func(t *testing.T) {
+			assert.Equal(t, tt.matches, matchesNoProxy(tt.addr, tt.noProxy))
+		})
+	}
+}
+
+// fakeHTTPProxy handles CONNECT request and tunnels the connection, proxy authorization is required if it is not empty
+func fakeHTTPProxy(authorization string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return
+		}
+		if req.Method != http.MethodConnect {
+			fmt.Fprint(conn, "HTTP/1.1 405 Method Not Allowed\r\n\r\n")
+			return
+		}
+		if authorization != "" && req.Header.Get("Proxy-Authorization") != authorization {
+			fmt.Fprint(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			fmt.Fprint(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return
+		}
+		defer target.Close()
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		tunnel(conn, target)
+	}
+}
+
+// fakeSOCKS5Proxy handles SOCKS5 connect request and tunnels the connection, username and password authentication is
+// required if username is not empty. Requested host is sent to hosts channel if it is not nil.
+func fakeSOCKS5Proxy(t *testing.T, username, password string, hosts chan<- string) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		methods := make([]byte, header[1])
+		io.ReadFull(conn, methods)
+		if username == "" {
+			conn.Write([]byte{0x05, 0x00})
+		} else {
+			conn.Write([]byte{0x05, 0x02})
+			auth := make([]byte, 2)
+			io.ReadFull(conn, auth)
+			u := make([]byte, auth[1])
+			io.ReadFull(conn, u)
+			size := make([]byte, 1)
+			io.ReadFull(conn, size)
+			p := make([]byte, size[0])
+			io.ReadFull(conn, p)
+			if string(u) != username || string(p) != password {
+				conn.Write([]byte{0x01, 0x01})
+				return
+			}
+			conn.Write([]byte{0x01, 0x00})
+		}
+
+		request := make([]byte, 4)
+		io.ReadFull(conn, request)
+		var host string
+		switch request[3] {
+		case 0x01:
+			ip := make([]byte, net.IPv4len)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x04:
+			ip := make([]byte, net.IPv6len)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			size := make([]byte, 1)
+			io.ReadFull(conn, size)
+			name := make([]byte, size[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		default:
+			t.Errorf("unexpected address type %d", request[3])
+			return
+		}
+		if hosts != nil {
+			hosts <- host
+		}
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+		target, err := net.Dial("tcp", net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+		if err != nil {
+			conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0, 0})
+		tunnel(conn, target)
+	}
+}
+
+func tunnel(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,80 @@
+package cert
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+const (
+	tpktVersion           = 3
+	x224ConnectionRequest = 0xe0
+	x224ConnectionConfirm = 0xd0
+
+	rdpNegotiationRequest  = 0x01
+	rdpNegotiationResponse = 0x02
+	rdpNegotiationFailure  = 0x03
+
+	// requested protocols, CredSSP (hybrid) starts with TLS handshake as well
+	rdpProtocolSSL    = 0x00000001
+	rdpProtocolHybrid = 0x00000002
+)
+
+// RDP, MS-RDPBCGR X.224 connection request with RDP negotiation request, TLS handshake starts after connection confirm
+func rdpStartTLS(conn net.Conn, _ string) error {
+
+	// TPKT header (4), X.224 connection request (7), RDP negotiation request (8)
+	request := []byte{tpktVersion, 0, 0, 19, 14, x224ConnectionRequest, 0, 0, 0, 0, 0, rdpNegotiationRequest, 0, 8, 0}
+	request = binary.LittleEndian.AppendUint32(request, rdpProtocolSSL|rdpProtocolHybrid)
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	tpdu, err := readTPKT(conn)
+	if err != nil {
+		return fmt.Errorf("connection confirm: %w", err)
+	}
+	if len(tpdu) < 7 || tpdu[1]&0xf0 != x224ConnectionConfirm {
+		return errors.New("connection confirm: unexpected x.224 response")
+	}
+	// RDP negotiation response, type (1), flags (1), length (2), selected protocol or failure code (4)
+	negotiation := tpdu[7:]
+	if len(negotiation) < 8 {
+		return errors.New("server supports only standard RDP security")
+	}
+	value := binary.LittleEndian.Uint32(negotiation[4:8])
+	switch negotiation[0] {
+	case rdpNegotiationResponse:
+		if value&(rdpProtocolSSL|rdpProtocolHybrid) == 0 {
+			return fmt.Errorf("server selected protocol %d without TLS", value)
+		}
+		return nil
+	case rdpNegotiationFailure:
+		return fmt.Errorf("negotiation failure code %d", value)
+	default:
+		return fmt.Errorf("unexpected negotiation type %d", negotiation[0])
+	}
+}
+
+// readTPKT reads TPKT (RFC 1006) packet and returns X.224 TPDU
+func readTPKT(r io.Reader) ([]byte, error) {
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if header[0] != tpktVersion {
+		return nil, fmt.Errorf("unexpected tpkt version %d", header[0])
+	}
+	length := int(binary.BigEndian.Uint16(header[2:4]))
+	if length < len(header) {
+		return nil, fmt.Errorf("invalid tpkt length %d", length)
+	}
+	tpdu := make([]byte, length-len(header))
+	if _, err := io.ReadFull(r, tpdu); err != nil {
+		return nil, err
+	}
+	return tpdu, nil
+}
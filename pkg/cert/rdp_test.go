@@ -0,0 +1,42 @@
+package cert
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_rdp(t *testing.T) {
+	t.Run("given rdp server then certificates are loaded after x.224 connection confirm", func(t *testing.T) {
+		addr := startTestServer(t, fakeRDPServer(testServerTLSConfig(t), rdpNegotiationResponse, rdpProtocolHybrid))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "rdp", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given rdp server negotiation failure then error is returned", func(t *testing.T) {
+		// SSL_NOT_ALLOWED_BY_SERVER
+		addr := startTestServer(t, fakeRDPServer(testServerTLSConfig(t), rdpNegotiationFailure, 2))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "rdp", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "negotiation failure code 2")
+	})
+}
+
+func fakeRDPServer(tlsConfig *tls.Config, negotiationType byte, value uint32) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		request, err := readTPKT(conn)
+		if err != nil || request[1] != x224ConnectionRequest || request[7] != rdpNegotiationRequest {
+			return
+		}
+		confirm := []byte{tpktVersion, 0, 0, 19, 14, x224ConnectionConfirm, 0, 0, 0, 0, 0, negotiationType, 0, 8, 0}
+		conn.Write(binary.LittleEndian.AppendUint32(confirm, value))
+		if negotiationType == rdpNegotiationResponse {
+			tls.Server(conn, tlsConfig).Handshake()
+		}
+	}
+}
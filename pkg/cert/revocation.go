@@ -0,0 +1,242 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	RevocationNone = "none"
+	RevocationOCSP = "ocsp"
+
+	ocspRequestContentType  = "application/ocsp-request"
+	ocspResponseContentType = "application/ocsp-response"
+	// requests shorter than this are sent by GET, so the responses can be cached (RFC 5019)
+	maxOCSPGetRequestSize = 255
+	maxOCSPResponseSize   = 1 << 20
+)
+
+// oidSHA1 is hash algorithm of OCSP request CertID, SHA-1 is required to be supported by all responders (RFC 5019)
+var oidSHA1 = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+
+// Revocation is online revocation check of a certificate
+type Revocation struct {
+	// Responder is URL of the OCSP responder that returned the response
+	Responder string
+	Response  OCSPResponse
+	// Error is set if none of the responders returned valid response for the certificate
+	Error error
+}
+
+// OCSPRequest ::= SEQUENCE {
+// tbsRequest        TBSRequest,
+// optionalSignature [0] EXPLICIT Signature OPTIONAL }
+//
+// TBSRequest ::= SEQUENCE {
+// version           [0] EXPLICIT Version DEFAULT v1,
+// requestorName     [1] EXPLICIT GeneralName OPTIONAL,
+// requestList       SEQUENCE OF Request,
+// requestExtensions [2] EXPLICIT Extensions OPTIONAL }
+//
+// Request ::= SEQUENCE {
+// reqCert                 CertID,
+// singleRequestExtensions [0] EXPLICIT Extensions OPTIONAL }
+type ocspRequest struct {
+	TBSRequest ocspTBSRequest
+}
+
+type ocspTBSRequest struct {
+	RequestList []ocspSingleRequest
+}
+
+type ocspSingleRequest struct {
+	Cert ocspCertID
+}
+
+// CheckOCSP checks revocation of every certificate in the location with OCSP responders from authority information
+// access extension. Issuer is searched in the location certificates, trust intermediates and verified chain, self-signed
+// certificates are not checked. Responders are connected through the proxy set by options or HTTP_PROXY environment
+// variable.
+func (c CertificateLocation) CheckOCSP(ctx context.Context, options NetworkOptions) CertificateLocation {
+
+	if c.Error != nil || len(c.Certificates) == 0 {
+		return c
+	}
+	issuers := c.issuerCandidates(options.Trust)
+	// connections are reused only by certificates of the location, so they are not kept open until the program exits
+	client := options.ocspClient()
+	defer client.CloseIdleConnections()
+
+	certificates := slices.Clone(c.Certificates)
+	for i, certificate := range certificates {
+		if certificate.err != nil || isSelfSigned(certificate.x509Certificate) {
+			continue
+		}
+		revocation := checkOCSP(ctx, client, certificate.x509Certificate, ocspIssuer(certificate.x509Certificate, issuers))
+		if revocation.Error != nil {
+			revocation.Error = cancelledError(ctx, revocation.Error)
+			slog.Debug(fmt.Sprintf("ocsp %s certificate %s: %v", c.Path, certificate.SubjectString(), revocation.Error))
+		}
+		certificates[i].revocation = &revocation
+	}
+	c.Certificates = certificates
+	return c
+}
+
+// issuerCandidates returns location certificates, trust intermediates and certificates of verified chains (with roots)
+func (c CertificateLocation) issuerCandidates(trust Trust) []*x509.Certificate {
+
+	var out []*x509.Certificate
+	for _, certificate := range c.Certificates {
+		if certificate.x509Certificate != nil {
+			out = append(out, certificate.x509Certificate)
+		}
+	}
+	out = append(out, trust.Intermediates...)
+	// chains are used only to find roots that are not in the location, verification error is not relevant
+	chains, _ := c.Chains(trust)
+	for _, chain := range chains {
+		for _, certificate := range chain {
+			out = append(out, certificate.x509Certificate)
+		}
+	}
+	return out
+}
+
+// isSelfSigned checks if the certificate is issued by itself (root), there is no issuer to check revocation with
+func isSelfSigned(certificate *x509.Certificate) bool {
+	return bytes.Equal(certificate.RawIssuer, certificate.RawSubject)
+}
+
+func (n NetworkOptions) ocspClient() *http.Client {
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy:       n.ocspProxy,
+			DialContext: (&net.Dialer{Timeout: n.connectTimeout()}).DialContext,
+		},
+		Timeout: n.connectTimeout() + n.handshakeTimeout(),
+	}
+}
+
+// ocspProxy returns proxy set by options, otherwise HTTP_PROXY (or HTTPS_PROXY for https responder) environment
+// variable is used, responders are plain HTTP
+func (n NetworkOptions) ocspProxy(request *http.Request) (*url.URL, error) {
+
+	if n.Proxy == ProxyNone {
+		return nil, nil
+	}
+	if n.Proxy != "" {
+		return parseProxy(n.Proxy)
+	}
+	return http.ProxyFromEnvironment(request)
+}
+
+// checkOCSP sends OCSP request for the certificate to its responders and returns the first valid response
+func checkOCSP(ctx context.Context, client *http.Client, certificate, issuer *x509.Certificate) Revocation {
+
+	if len(certificate.OCSPServer) == 0 {
+		return Revocation{Error: errors.New("certificate has no OCSP responder")}
+	}
+	if issuer == nil {
+		return Revocation{Error: errors.New("issuer certificate not found")}
+	}
+	request, err := createOCSPRequest(certificate, issuer)
+	if err != nil {
+		return Revocation{Error: err}
+	}
+
+	var errs []error
+	for _, responder := range certificate.OCSPServer {
+		der, err := sendOCSPRequest(ctx, client, responder, request)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", responder, err))
+			continue
+		}
+		response, err := ParseOCSPResponse(der, certificate, issuer)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", responder, err))
+			continue
+		}
+		return Revocation{Responder: responder, Response: response}
+	}
+	return Revocation{Error: errors.Join(errs...)}
+}
+
+// createOCSPRequest returns DER OCSP request for the certificate without nonce, so the response can be cached by the
+// responder
+func createOCSPRequest(certificate, issuer *x509.Certificate) ([]byte, error) {
+
+	nameHash, keyHash, err := issuerHashes(issuer, oidSHA1)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(ocspRequest{TBSRequest: ocspTBSRequest{RequestList: []ocspSingleRequest{{
+		Cert: ocspCertID{
+			HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+			IssuerNameHash: nameHash,
+			IssuerKeyHash:  keyHash,
+			SerialNumber:   certificate.SerialNumber,
+		},
+	}}}})
+}
+
+// sendOCSPRequest sends the request by GET if it is short enough, otherwise (or if GET fails) by POST
+func sendOCSPRequest(ctx context.Context, client *http.Client, responder string, request []byte) ([]byte, error) {
+
+	encoded := base64.StdEncoding.EncodeToString(request)
+	if len(encoded) < maxOCSPGetRequestSize {
+		getURL := strings.TrimSuffix(responder, "/") + "/" + url.QueryEscape(encoded)
+		httpRequest, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		der, err := doOCSPRequest(client, httpRequest)
+		if err == nil || ctx.Err() != nil {
+			return der, err
+		}
+		slog.Debug(fmt.Sprintf("ocsp GET %s: %v, trying POST", responder, err))
+	}
+
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, responder, bytes.NewReader(request))
+	if err != nil {
+		return nil, err
+	}
+	httpRequest.Header.Set("Content-Type", ocspRequestContentType)
+	return doOCSPRequest(client, httpRequest)
+}
+
+func doOCSPRequest(client *http.Client, request *http.Request) ([]byte, error) {
+
+	request.Header.Set("Accept", ocspResponseContentType)
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s %s", request.Method, response.Status)
+	}
+	der, err := io.ReadAll(io.LimitReader(response.Body, maxOCSPResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(der) > maxOCSPResponseSize {
+		return nil, fmt.Errorf("%s response too large", request.Method)
+	}
+	return der, nil
+}
@@ -0,0 +1,192 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateLocation_CheckOCSP(t *testing.T) {
+	pki := newTestPKI(t)
+	trust := Trust{Roots: poolOf(pki.root)}
+
+	t.Run("given responder returns good response then certificate status is good", func(t *testing.T) {
+		responder := startTestOCSPResponder(t, func(certificate *x509.Certificate) []byte {
+			return createTestOCSPResponse(t, testOCSPResponse{certificate: certificate, issuer: pki.intermediate,
+				signer: pki.intermediate, signerKey: pki.intermediateKey})
+		})
+		leaf := testOCSPLeaf(t, pki, responder.URL)
+		responder.certificate = leaf
+		location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{leaf, pki.intermediate, pki.root})}
+
+		location = location.CheckOCSP(t.Context(), NetworkOptions{Proxy: ProxyNone, Trust: trust})
+		require.Equal(t, 3, len(location.Certificates))
+
+		revocation := location.Certificates[0].Revocation()
+		require.NotNil(t, revocation)
+		require.NoError(t, revocation.Error)
+		assert.Equal(t, OCSPStatusGood, revocation.Response.Status)
+		assert.NoError(t, revocation.Response.SignatureError)
+		assert.Equal(t, responder.URL, revocation.Responder)
+		assert.Equal(t, []string{http.MethodGet}, responder.methods())
+
+		// intermediate does not have OCSP responder and root is not checked
+		require.NotNil(t, location.Certificates[1].Revocation())
+		assert.EqualError(t, location.Certificates[1].Revocation().Error, "certificate has no OCSP responder")
+		assert.Nil(t, location.Certificates[2].Revocation())
+	})
+
+	t.Run("given responder returns revoked response signed by delegated responder then certificate is revoked", func(t *testing.T) {
+		delegated, delegatedKey := testOCSPResponder(t, pki, []x509.ExtKeyUsage{x509.ExtKeyUsageOCSPSigning}, time.Now().Add(time.Hour))
+		revokedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		responder := startTestOCSPResponder(t, func(certificate *x509.Certificate) []byte {
+			return createTestOCSPResponse(t, testOCSPResponse{certificate: certificate, issuer: pki.intermediate,
+				signer: delegated, signerKey: delegatedKey, certificates: []*x509.Certificate{delegated}, revokedAt: revokedAt, reason: 4})
+		})
+		leaf := testOCSPLeaf(t, pki, responder.URL)
+		responder.certificate = leaf
+		// issuer is found in trust intermediates
+		location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{leaf})}
+
+		location = location.CheckOCSP(t.Context(), NetworkOptions{Proxy: ProxyNone, Trust: Trust{Intermediates: []*x509.Certificate{pki.intermediate}}})
+		revocation := location.Certificates[0].Revocation()
+		require.NotNil(t, revocation)
+		require.NoError(t, revocation.Error)
+		assert.Equal(t, OCSPStatusRevoked, revocation.Response.Status)
+		assert.Equal(t, revokedAt, revocation.Response.RevokedAt)
+		assert.Equal(t, "superseded", revocation.Response.RevocationReason)
+		assert.NoError(t, revocation.Response.SignatureError)
+	})
+
+	t.Run("given responder does not support get then request is sent by post", func(t *testing.T) {
+		responder := startTestOCSPResponder(t, func(certificate *x509.Certificate) []byte {
+			return createTestOCSPResponse(t, testOCSPResponse{certificate: certificate, issuer: pki.intermediate,
+				signer: pki.intermediate, signerKey: pki.intermediateKey})
+		})
+		responder.postOnly = true
+		leaf := testOCSPLeaf(t, pki, responder.URL)
+		responder.certificate = leaf
+		location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{leaf, pki.intermediate})}
+
+		location = location.CheckOCSP(t.Context(), NetworkOptions{Proxy: ProxyNone})
+		revocation := location.Certificates[0].Revocation()
+		require.NotNil(t, revocation)
+		require.NoError(t, revocation.Error)
+		assert.Equal(t, OCSPStatusGood, revocation.Response.Status)
+		assert.Equal(t, []string{http.MethodGet, http.MethodPost}, responder.methods())
+	})
+
+	t.Run("given issuer is not found then error is set", func(t *testing.T) {
+		leaf := testOCSPLeaf(t, pki, "http://127.0.0.1:1")
+		location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{leaf})}
+
+		location = location.CheckOCSP(t.Context(), NetworkOptions{Proxy: ProxyNone})
+		revocation := location.Certificates[0].Revocation()
+		require.NotNil(t, revocation)
+		assert.EqualError(t, revocation.Error, "issuer certificate not found")
+	})
+
+	t.Run("given responder fails then error is set", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+		leaf := testOCSPLeaf(t, pki, server.URL)
+		location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{leaf, pki.intermediate})}
+
+		location = location.CheckOCSP(t.Context(), NetworkOptions{Proxy: ProxyNone})
+		revocation := location.Certificates[0].Revocation()
+		require.NotNil(t, revocation)
+		assert.ErrorContains(t, revocation.Error, "POST 500 Internal Server Error")
+	})
+}
+
+type testOCSPResponderServer struct {
+	*httptest.Server
+	// certificate is the certificate the responder responds for, it is set after the server is started, because the
+	// certificate contains responder URL
+	certificate *x509.Certificate
+	postOnly    bool
+	mu          sync.Mutex
+	requests    []string
+}
+
+func (s *testOCSPResponderServer) methods() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// startTestOCSPResponder starts OCSP responder that decodes GET and POST requests for the responder certificate and
+// returns response by the function
+func startTestOCSPResponder(t *testing.T, respond func(certificate *x509.Certificate) []byte) *testOCSPResponderServer {
+	responder := &testOCSPResponderServer{}
+	responder.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		responder.mu.Lock()
+		responder.requests = append(responder.requests, r.Method)
+		responder.mu.Unlock()
+
+		var der []byte
+		switch {
+		case r.Method == http.MethodGet && !responder.postOnly:
+			encoded, err := url.QueryUnescape(strings.TrimPrefix(r.URL.EscapedPath(), "/"))
+			if err == nil {
+				der, err = base64.StdEncoding.DecodeString(encoded)
+			}
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		case r.Method == http.MethodPost && r.Header.Get("Content-Type") == ocspRequestContentType:
+			der, _ = io.ReadAll(r.Body)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var request ocspRequest
+		if _, err := asn1.Unmarshal(der, &request); err != nil || len(request.TBSRequest.RequestList) != 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if request.TBSRequest.RequestList[0].Cert.SerialNumber.Cmp(responder.certificate.SerialNumber) != 0 {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", ocspResponseContentType)
+		w.Write(respond(responder.certificate))
+	}))
+	t.Cleanup(responder.Close)
+	return responder
+}
+
+// testOCSPLeaf returns leaf certificate with OCSP responder URL issued by the test intermediate
+func testOCSPLeaf(t *testing.T, pki testPKI, responder string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(6),
+		Subject:      pkix.Name{CommonName: "certinfo.test"},
+		DNSNames:     []string{"certinfo.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		OCSPServer:   []string{responder},
+	}, pki.intermediate, key, pki.intermediateKey)
+}
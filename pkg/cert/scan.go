@@ -0,0 +1,390 @@
+package cert
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// versions tested by scan
+var scanVersions = []uint16{tls.VersionSSL30, tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13}
+
+// TLSScan is TLS versions and cipher suites accepted by the server
+type TLSScan struct {
+	Versions []VersionScan
+	// ServerPreference is set if the server selects cipher suite by its own preference, nil if it could not be
+	// determined (less than two cipher suites accepted by TLS 1.2 and lower)
+	ServerPreference *bool
+}
+
+// VersionScan is cipher suites accepted by the server for TLS version, TLS 1.3 cipher suites are not configurable in
+// crypto/tls, so only the negotiated TLS 1.3 cipher suite is reported
+type VersionScan struct {
+	Version      uint16
+	Accepted     bool
+	CipherSuites []uint16
+}
+
+// VersionString returns TLS version, old versions are flagged as deprecated
+func (v VersionScan) VersionString() string {
+	return tlsFormat(v.Version)
+}
+
+// CipherSuiteStrings returns accepted cipher suites, deprecated cipher suites are flagged
+func (v VersionScan) CipherSuiteStrings() []string {
+
+	var out []string
+	for _, cipherSuite := range v.CipherSuites {
+		out = append(out, cipherSuiteFormat(cipherSuite))
+	}
+	return out
+}
+
+// Grade returns grade of the accepted versions and cipher suites with reasons of the grade:
+//   - F SSLv3 or insecure cipher suites (NULL, anonymous, EXPORT, DES, RC2, RC4, 3DES)
+//   - C TLS 1.0 or 1.1
+//   - B deprecated cipher suites (CBC mode, key exchange without forward secrecy)
+//   - A only TLS 1.2 and 1.3 with forward secrecy AEAD cipher suites, A+ if TLS 1.3 is accepted as well
+func (s TLSScan) Grade() (string, []string) {
+
+	var ssl3, insecure, oldVersion, deprecated, tls13 bool
+	for _, version := range s.Versions {
+		if !version.Accepted {
+			continue
+		}
+		switch version.Version {
+		case tls.VersionSSL30:
+			ssl3 = true
+		case tls.VersionTLS10, tls.VersionTLS11:
+			oldVersion = true
+		case tls.VersionTLS13:
+			tls13 = true
+		}
+		for _, cipherSuite := range version.CipherSuites {
+			if isInsecureCipherSuite(cipherSuite) {
+				insecure = true
+			}
+			if isDeprecatedCipherSuite(cipherSuite) {
+				deprecated = true
+			}
+		}
+	}
+
+	var reasons []string
+	if ssl3 {
+		reasons = append(reasons, "SSLv3 accepted")
+	}
+	if insecure {
+		reasons = append(reasons, "insecure cipher suites (NULL, anonymous, EXPORT, DES, RC2, RC4, 3DES)")
+	}
+	if oldVersion {
+		reasons = append(reasons, "TLS 1.0 or 1.1 accepted")
+	}
+	if deprecated {
+		reasons = append(reasons, "deprecated cipher suites (CBC mode, key exchange without forward secrecy)")
+	}
+	switch {
+	case ssl3, insecure:
+		return "F", reasons
+	case oldVersion:
+		return "C", reasons
+	case deprecated:
+		return "B", reasons
+	case tls13:
+		return "A+", reasons
+	default:
+		return "A", append(reasons, "TLS 1.3 not accepted")
+	}
+}
+
+// isInsecureCipherSuite checks if the cipher suite does not encrypt or authenticate, or uses broken cipher
+func isInsecureCipherSuite(cipherSuite uint16) bool {
+
+	name := cipherSuiteName(cipherSuite)
+	for _, insecure := range []string{"_NULL_", "_anon_", "_EXPORT_", "_DES_", "_DES40_", "_RC2_", "_RC4_", "_3DES_"} {
+		if strings.Contains(name, insecure) {
+			return true
+		}
+	}
+	return false
+}
+
+// scanTLS sends ClientHello for every TLS version and cipher suite (TLS 1.3 handshake is done by crypto/tls) and checks
+// if the server enforces its cipher suite preference, scan fails only if the server cannot be connected, rejected
+// handshakes are not accepted
+func scanTLS(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions) (*TLSScan, error) {
+
+	options.InsecureSkipVerify = true
+	// ALPN is not offered, server that rejects offered application protocol rejects every version
+	options.NextProtos = nil
+	scan := &TLSScan{}
+	for _, version := range scanVersions {
+		versionScan, err := scanVersion(ctx, addr, proxy, options, version)
+		if err != nil {
+			return nil, err
+		}
+		scan.Versions = append(scan.Versions, versionScan)
+	}
+
+	// preference is checked on the highest version with configurable cipher suites
+	for _, versionScan := range slices.Backward(scan.Versions) {
+		if versionScan.Version == tls.VersionTLS13 || len(versionScan.CipherSuites) < 2 {
+			continue
+		}
+		preference, err := serverPreference(ctx, addr, proxy, options, versionScan)
+		if err != nil {
+			if isConnectError(ctx, err) {
+				return nil, err
+			}
+			slog.Debug(fmt.Sprintf("server cipher preference %s: %v", addr, err))
+			break
+		}
+		scan.ServerPreference = &preference
+		break
+	}
+	return scan, nil
+}
+
+func scanVersion(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions, version uint16) (VersionScan, error) {
+
+	versionScan := VersionScan{Version: version}
+	if version == tls.VersionTLS13 {
+		options.TLSVersion = version
+		cipherSuite, err := probeHandshake(ctx, addr, proxy, options)
+		if err != nil || cipherSuite == 0 {
+			return versionScan, err
+		}
+		versionScan.Accepted = true
+		versionScan.CipherSuites = []uint16{cipherSuite}
+		return versionScan, nil
+	}
+
+	// ClientHello offers all IANA cipher suites, so versions and cipher suites not implemented by crypto/tls are
+	// detected as well. Cipher suite selected by the server is removed from the offered cipher suites until the server
+	// rejects the rest or negotiates different version.
+	offered := slices.Sorted(maps.Keys(cipherSuiteNames))
+	for len(offered) != 0 {
+		hello, err := helloServer(ctx, addr, proxy, options, version, offered)
+		if err != nil {
+			if isConnectError(ctx, err) {
+				return versionScan, err
+			}
+			break
+		}
+		i := slices.Index(offered, hello.cipherSuite)
+		if hello.version != version || i < 0 {
+			break
+		}
+		versionScan.Accepted = true
+		versionScan.CipherSuites = append(versionScan.CipherSuites, hello.cipherSuite)
+		offered = slices.Delete(offered, i, i+1)
+	}
+	slices.Sort(versionScan.CipherSuites)
+	return versionScan, nil
+}
+
+// probeHandshake returns negotiated cipher suite, 0 if the handshake was rejected. Error is returned only if the
+// server cannot be connected or the context is done.
+func probeHandshake(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions) (uint16, error) {
+
+	options.probeWait(ctx)
+	conn, _, err := dialTLS(ctx, addr, proxy, options)
+	if err != nil {
+		if isConnectError(ctx, err) {
+			return 0, err
+		}
+		return 0, nil
+	}
+	defer conn.Close()
+	return conn.ConnectionState().CipherSuite, nil
+}
+
+// isConnectError checks if the error is connect (or proxy) error or the context is done, as opposed to rejected
+// handshake
+func isConnectError(ctx context.Context, err error) bool {
+
+	if ctx.Err() != nil {
+		return true
+	}
+	var connectErr *connectError
+	return errors.As(err, &connectErr)
+}
+
+// serverPreference offers accepted cipher suites in two opposite orders, server that selects the same cipher suite
+// for both orders enforces its own preference. crypto/tls client orders cipher suites by its own preference, so the
+// ClientHello is sent directly.
+func serverPreference(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions, versionScan VersionScan) (bool, error) {
+
+	first, err := helloServer(ctx, addr, proxy, options, versionScan.Version, versionScan.CipherSuites)
+	if err != nil {
+		return false, err
+	}
+	reversed := slices.Clone(versionScan.CipherSuites)
+	slices.Reverse(reversed)
+	second, err := helloServer(ctx, addr, proxy, options, versionScan.Version, reversed)
+	if err != nil {
+		return false, err
+	}
+	return first.cipherSuite == second.cipherSuite, nil
+}
+
+// serverHello is version and cipher suite selected by the server
+type serverHello struct {
+	version     uint16
+	cipherSuite uint16
+}
+
+// helloServer sends ClientHello with cipher suites in the order and returns version and cipher suite selected by the
+// server in ServerHello, the handshake is not finished
+func helloServer(ctx context.Context, addr string, proxy *url.URL, options NetworkOptions, version uint16, cipherSuites []uint16) (serverHello, error) {
+
+	protocol, err := upgradeProtocol(addr, options.StartTLS)
+	if err != nil {
+		return serverHello{}, err
+	}
+	serverName := options.ServerName
+	if serverName == "" {
+		if serverName, _, err = net.SplitHostPort(addr); err != nil {
+			return serverHello{}, err
+		}
+	}
+	options.probeWait(ctx)
+	conn, stop, err := dialHandshake(ctx, addr, proxy, protocol, serverName, options)
+	if err != nil {
+		return serverHello{}, err
+	}
+	defer stop()
+	defer conn.Close()
+
+	hello, err := clientHello(version, cipherSuites, serverName)
+	if err != nil {
+		return serverHello{}, err
+	}
+	if _, err := conn.Write(hello); err != nil {
+		return serverHello{}, fmt.Errorf("client hello: %w", err)
+	}
+	return readServerHello(conn)
+}
+
+const (
+	recordTypeAlert     = 21
+	recordTypeHandshake = 22
+	// maximum TLS record payload size (plaintext and expansion)
+	maxRecordSize = 16384 + 2048
+)
+
+// clientHello returns TLS record with ClientHello message for the version (TLS 1.2 and lower), extensions are the ones
+// required by most servers to select ECDHE cipher suite, SSLv3 does not have extensions
+func clientHello(version uint16, cipherSuites []uint16, serverName string) ([]byte, error) {
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	body := binary.BigEndian.AppendUint16(nil, version)
+	body = append(body, random...)
+	// empty session id
+	body = append(body, 0)
+	body = binary.BigEndian.AppendUint16(body, uint16(2*len(cipherSuites)))
+	for _, cipherSuite := range cipherSuites {
+		body = binary.BigEndian.AppendUint16(body, cipherSuite)
+	}
+	// null compression
+	body = append(body, 1, 0)
+	if version == tls.VersionSSL30 {
+		return handshakeRecord(version, body), nil
+	}
+
+	var extensions []byte
+	if serverName != "" && net.ParseIP(serverName) == nil {
+		name := binary.BigEndian.AppendUint16([]byte{0}, uint16(len(serverName)))
+		name = append(name, serverName...)
+		extensions = appendExtension(extensions, 0x0000, binary.BigEndian.AppendUint16(nil, uint16(len(name))), name)
+	}
+	// supported groups x25519, secp256r1, secp384r1, secp521r1
+	groups := []byte{0x00, 0x1d, 0x00, 0x17, 0x00, 0x18, 0x00, 0x19}
+	extensions = appendExtension(extensions, 0x000a, binary.BigEndian.AppendUint16(nil, uint16(len(groups))), groups)
+	// uncompressed EC point format
+	extensions = appendExtension(extensions, 0x000b, []byte{1, 0})
+	// signature algorithms ECDSA, RSA-PSS and RSA PKCS#1 with SHA-256, SHA-384, SHA-512 and SHA-1
+	algorithms := []byte{0x04, 0x03, 0x08, 0x04, 0x04, 0x01, 0x05, 0x03, 0x08, 0x05, 0x05, 0x01, 0x08, 0x06, 0x06, 0x01,
+		0x02, 0x01, 0x02, 0x03}
+	extensions = appendExtension(extensions, 0x000d, binary.BigEndian.AppendUint16(nil, uint16(len(algorithms))), algorithms)
+	// extended master secret and empty renegotiation info
+	extensions = appendExtension(extensions, 0x0017)
+	extensions = appendExtension(extensions, 0xff01, []byte{0})
+	body = binary.BigEndian.AppendUint16(body, uint16(len(extensions)))
+	body = append(body, extensions...)
+	return handshakeRecord(version, body), nil
+}
+
+// handshakeRecord returns TLS record with ClientHello message body
+func handshakeRecord(version uint16, body []byte) []byte {
+
+	// handshake message type 1 (client hello) with 3 byte length
+	message := append([]byte{1, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body...)
+	// record version is TLS 1.0 for compatibility, SSLv3 servers do not accept it
+	record := []byte{recordTypeHandshake}
+	record = binary.BigEndian.AppendUint16(record, min(version, tls.VersionTLS10))
+	record = binary.BigEndian.AppendUint16(record, uint16(len(message)))
+	return append(record, message...)
+}
+
+func appendExtension(extensions []byte, extensionType uint16, data ...[]byte) []byte {
+
+	payload := slices.Concat(data...)
+	extensions = binary.BigEndian.AppendUint16(extensions, extensionType)
+	extensions = binary.BigEndian.AppendUint16(extensions, uint16(len(payload)))
+	return append(extensions, payload...)
+}
+
+// readServerHello reads ServerHello message and returns selected version and cipher suite, alert is returned as error
+func readServerHello(r io.Reader) (serverHello, error) {
+
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return serverHello{}, fmt.Errorf("server hello: %w", err)
+	}
+	size := int(binary.BigEndian.Uint16(header[3:]))
+	if size > maxRecordSize {
+		return serverHello{}, fmt.Errorf("server hello: record size %d too large", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return serverHello{}, fmt.Errorf("server hello: %w", err)
+	}
+
+	switch header[0] {
+	case recordTypeAlert:
+		if len(payload) == 2 {
+			return serverHello{}, fmt.Errorf("server hello: alert %d", payload[1])
+		}
+		return serverHello{}, errors.New("server hello: alert")
+	case recordTypeHandshake:
+	default:
+		return serverHello{}, fmt.Errorf("server hello: unexpected record type %d", header[0])
+	}
+
+	// message type (2 server hello), length (3), version (2), random (32), session id length and session id
+	if len(payload) < 39 || payload[0] != 2 {
+		return serverHello{}, errors.New("server hello: unexpected handshake message")
+	}
+	offset := 38 + 1 + int(payload[38])
+	if len(payload) < offset+2 {
+		return serverHello{}, errors.New("server hello: message too short")
+	}
+	return serverHello{
+		version:     binary.BigEndian.Uint16(payload[4:]),
+		cipherSuite: binary.BigEndian.Uint16(payload[offset:]),
+	}, nil
+}
@@ -0,0 +1,288 @@
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_scan(t *testing.T) {
+	t.Run("given tls 1.2 server with gcm and cbc cipher suites then accepted versions and cipher suites are returned", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.MaxVersion = tls.VersionTLS12
+		tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, Scan: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.Scan)
+		require.Equal(t, 5, len(location.Scan.Versions))
+
+		accepted := make(map[uint16][]uint16)
+		for _, version := range location.Scan.Versions {
+			if version.Accepted {
+				accepted[version.Version] = version.CipherSuites
+			}
+		}
+		assert.Equal(t, map[uint16][]uint16{
+			tls.VersionTLS12: {tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+		}, accepted)
+
+		// crypto/tls server selects cipher suite by its own preference
+		require.NotNil(t, location.Scan.ServerPreference)
+		assert.True(t, *location.Scan.ServerPreference)
+
+		grade, reasons := location.Scan.Grade()
+		assert.Equal(t, "B", grade)
+		assert.Equal(t, []string{"deprecated cipher suites (CBC mode, key exchange without forward secrecy)"}, reasons)
+	})
+
+	t.Run("given tls 1.3 server then tls 1.3 cipher suite is returned", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.MinVersion = tls.VersionTLS13
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, Scan: true})
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.Scan)
+		for _, version := range location.Scan.Versions {
+			assert.Equal(t, version.Version == tls.VersionTLS13, version.Accepted, version.VersionString())
+		}
+		assert.Nil(t, location.Scan.ServerPreference)
+		grade, _ := location.Scan.Grade()
+		assert.Equal(t, "A+", grade)
+	})
+
+	t.Run("given tls 1.1 only server with untrusted certificate then handshake fails and scan is returned", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.MinVersion = tls.VersionTLS10
+		tlsConfig.MaxVersion = tls.VersionTLS11
+		tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Scan: true})
+		require.Error(t, location.Error)
+		require.NoError(t, location.ScanError)
+		require.NotNil(t, location.Scan)
+
+		var accepted []uint16
+		for _, version := range location.Scan.Versions {
+			if version.Accepted {
+				accepted = append(accepted, version.Version)
+			}
+		}
+		assert.Equal(t, []uint16{tls.VersionTLS10, tls.VersionTLS11}, accepted)
+		grade, _ := location.Scan.Grade()
+		assert.Equal(t, "C", grade)
+	})
+
+	t.Run("given tls 1.0 only server with cipher suite not implemented by crypto/tls then tls 1.0 is accepted", func(t *testing.T) {
+		// TLS_DHE_RSA_WITH_AES_256_CBC_SHA
+		addr := startTestServer(t, helloServerHandler(tls.VersionTLS10, 0x0039))
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true, Scan: true})
+		require.Error(t, location.Error)
+		require.NoError(t, location.ScanError)
+		require.NotNil(t, location.Scan)
+
+		accepted := make(map[uint16][]uint16)
+		for _, version := range location.Scan.Versions {
+			if version.Accepted {
+				accepted[version.Version] = version.CipherSuites
+			}
+		}
+		assert.Equal(t, map[uint16][]uint16{tls.VersionTLS10: {0x0039}}, accepted)
+		grade, _ := location.Scan.Grade()
+		assert.Equal(t, "C", grade)
+	})
+
+	t.Run("given probe wait then every scan probe waits", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		tlsConfig.MaxVersion = tls.VersionTLS12
+		tlsConfig.CipherSuites = []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA}
+		var accepted atomic.Int32
+		addr := startTestServer(t, func(conn net.Conn) {
+			accepted.Add(1)
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+
+		var waits atomic.Int32
+		options := NetworkOptions{InsecureSkipVerify: true, Scan: true, ProbeWait: func(context.Context) { waits.Add(1) }}
+		location := LoadCertificatesFromNetwork(t.Context(), addr, options)
+		require.NoError(t, location.Error)
+		require.NotNil(t, location.Scan)
+		// first handshake is rate limited by the caller
+		assert.Equal(t, accepted.Load()-1, waits.Load())
+	})
+
+	t.Run("given server cannot be connected then scan error is set", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := listener.Addr().String()
+		require.NoError(t, listener.Close())
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Scan: true})
+		require.Error(t, location.Error)
+		assert.Error(t, location.ScanError)
+		assert.Nil(t, location.Scan)
+	})
+
+	t.Run("given scan is not set then scan is not returned", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			tls.Server(conn, testServerTLSConfig(t)).Handshake()
+		})
+
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		assert.Nil(t, location.Scan)
+	})
+}
+
+func TestTLSScan_Grade(t *testing.T) {
+	t.Run("given insecure cipher suite then grade is F", func(t *testing.T) {
+		scan := TLSScan{Versions: []VersionScan{
+			{Version: tls.VersionTLS12, Accepted: true, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA}},
+		}}
+		grade, reasons := scan.Grade()
+		assert.Equal(t, "F", grade)
+		assert.Contains(t, reasons, "insecure cipher suites (NULL, anonymous, EXPORT, DES, RC2, RC4, 3DES)")
+	})
+
+	t.Run("given sslv3 then grade is F", func(t *testing.T) {
+		scan := TLSScan{Versions: []VersionScan{
+			{Version: tls.VersionSSL30, Accepted: true, CipherSuites: []uint16{0x0039}},
+			{Version: tls.VersionTLS12, Accepted: true, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+		}}
+		grade, reasons := scan.Grade()
+		assert.Equal(t, "F", grade)
+		assert.Contains(t, reasons, "SSLv3 accepted")
+	})
+
+	t.Run("given tls 1.0 with cipher suite not implemented by crypto/tls then grade is C", func(t *testing.T) {
+		scan := TLSScan{Versions: []VersionScan{
+			// TLS_DHE_RSA_WITH_AES_256_CBC_SHA
+			{Version: tls.VersionTLS10, Accepted: true, CipherSuites: []uint16{0x0039}},
+			{Version: tls.VersionTLS12, Accepted: true, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+		}}
+		grade, _ := scan.Grade()
+		assert.Equal(t, "C", grade)
+		assert.Equal(t, []string{"TLS_DHE_RSA_WITH_AES_256_CBC_SHA - Deprecated!"}, scan.Versions[0].CipherSuiteStrings())
+	})
+
+	t.Run("given tls 1.0 then grade is C", func(t *testing.T) {
+		scan := TLSScan{Versions: []VersionScan{
+			{Version: tls.VersionTLS10, Accepted: true, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA}},
+			{Version: tls.VersionTLS13, Accepted: true, CipherSuites: []uint16{tls.TLS_AES_128_GCM_SHA256}},
+		}}
+		grade, _ := scan.Grade()
+		assert.Equal(t, "C", grade)
+	})
+
+	t.Run("given only tls 1.2 with aead cipher suites then grade is A", func(t *testing.T) {
+		scan := TLSScan{Versions: []VersionScan{
+			{Version: tls.VersionTLS10},
+			{Version: tls.VersionTLS12, Accepted: true, CipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+			{Version: tls.VersionTLS13},
+		}}
+		grade, reasons := scan.Grade()
+		assert.Equal(t, "A", grade)
+		assert.Equal(t, []string{"TLS 1.3 not accepted"}, reasons)
+	})
+}
+
+func Test_isConnectError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+
+	t.Run("given closed port then dial error is connect error", func(t *testing.T) {
+		_, _, err := dialTLS(t.Context(), closedAddr, nil, NetworkOptions{})
+		require.Error(t, err)
+		assert.True(t, isConnectError(t.Context(), err))
+	})
+
+	t.Run("given unreachable proxy then proxy error is connect error", func(t *testing.T) {
+		_, _, err := dialTLS(t.Context(), "example.com:443", &url.URL{Scheme: "http", Host: closedAddr}, NetworkOptions{})
+		require.ErrorContains(t, err, "proxy http://"+closedAddr)
+		assert.True(t, isConnectError(t.Context(), err))
+	})
+
+	t.Run("given server rejects handshake then handshake error is not connect error", func(t *testing.T) {
+		addr := startTestServer(t, func(conn net.Conn) {
+			conn.Close()
+		})
+		_, _, err := dialTLS(t.Context(), addr, nil, NetworkOptions{})
+		require.Error(t, err)
+		assert.False(t, isConnectError(t.Context(), err))
+	})
+}
+
+func Test_readServerHello(t *testing.T) {
+	t.Run("given server hello then version and cipher suite are returned", func(t *testing.T) {
+		// server hello type, length, version, random, session id (1 byte), cipher suite, compression
+		message := []byte{2, 0, 0, 39, 3, 3}
+		message = append(message, make([]byte, 32)...)
+		message = append(message, 1, 7, 0xc0, 0x2b, 0)
+		record := append([]byte{recordTypeHandshake, 3, 3, 0, byte(len(message))}, message...)
+
+		hello, err := readServerHello(bytes.NewReader(record))
+		require.NoError(t, err)
+		assert.Equal(t, serverHello{version: tls.VersionTLS12, cipherSuite: tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}, hello)
+	})
+
+	t.Run("given alert then error is returned", func(t *testing.T) {
+		_, err := readServerHello(bytes.NewReader([]byte{recordTypeAlert, 3, 3, 0, 2, 2, 40}))
+		assert.EqualError(t, err, "server hello: alert 40")
+	})
+}
+
+// helloServerHandler replies with ServerHello to ClientHello with the version that offers the cipher suite, otherwise
+// handshake failure alert is sent
+func helloServerHandler(version, cipherSuite uint16) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		header := make([]byte, 5)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		payload := make([]byte, binary.BigEndian.Uint16(header[3:]))
+		if _, err := io.ReadFull(conn, payload); err != nil || len(payload) < 41 {
+			return
+		}
+		// message type (1), length (3), version (2), random (32), session id length and session id
+		offset := 39 + int(payload[38])
+		size := int(binary.BigEndian.Uint16(payload[offset:]))
+		var offered bool
+		for i := offset + 2; i+1 < offset+2+size && i+1 < len(payload); i += 2 {
+			offered = offered || binary.BigEndian.Uint16(payload[i:]) == cipherSuite
+		}
+		if binary.BigEndian.Uint16(payload[4:]) != version || !offered {
+			conn.Write([]byte{recordTypeAlert, 3, 1, 0, 2, 2, 40})
+			return
+		}
+
+		// version, random, empty session id, cipher suite and null compression
+		body := binary.BigEndian.AppendUint16(nil, version)
+		body = append(body, make([]byte, 32)...)
+		body = append(body, 0)
+		body = binary.BigEndian.AppendUint16(body, cipherSuite)
+		body = append(body, 0)
+		message := append([]byte{2, 0, 0, byte(len(body))}, body...)
+		conn.Write(append([]byte{recordTypeHandshake, 3, 1, 0, byte(len(message))}, message...))
+	}
+}
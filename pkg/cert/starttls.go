@@ -0,0 +1,237 @@
+package cert
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+)
+
+const (
+	StartTLSAuto = "auto"
+	StartTLSNone = "none"
+
+	maxUpgradeResponseSize = 64 * 1024
+)
+
+// upgradeFunc speaks plain text protocol on the connection up to the point where TLS handshake can start, server name
+// is used by protocols that address virtual host (e.g. XMPP domain)
+type upgradeFunc func(conn net.Conn, serverName string) error
+
+var upgradesByProtocol = map[string]upgradeFunc{
+	"smtp": smtpStartTLS,
+	"imap": imapStartTLS,
+	"pop3": pop3StartTLS,
+	"ldap": ldapStartTLS,
+	"xmpp": xmppStartTLS,
+	"ftp":  ftpStartTLS,
+	"nntp": nntpStartTLS,
+	// not STARTTLS, but protocol specific upgrade as well
+	"postgres": postgresStartTLS,
+	"mysql":    mysqlStartTLS,
+	"rdp":      rdpStartTLS,
+	"mssql":    mssqlStartTLS,
+}
+
+// handshakeConnByProtocol wraps connection for protocols that frame TLS handshake records
+var handshakeConnByProtocol = map[string]func(conn net.Conn) net.Conn{
+	"mssql": newTDSConn,
+}
+
+// well-known ports of protocols that upgrade plain text connection, used when protocol is set to auto
+var protocolsByPort = map[string]string{
+	"25":   "smtp",
+	"587":  "smtp",
+	"143":  "imap",
+	"110":  "pop3",
+	"389":  "ldap",
+	"5222": "xmpp",
+	"21":   "ftp",
+	"119":  "nntp",
+	"5432": "postgres",
+	"3306": "mysql",
+	"3389": "rdp",
+	"1433": "mssql",
+}
+
+// upgradeProtocol returns protocol to use for the address, empty string means direct TLS
+func upgradeProtocol(addr, protocol string) (string, error) {
+
+	switch protocol {
+	case "", StartTLSNone:
+		return "", nil
+	case StartTLSAuto:
+		_, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return "", err
+		}
+		return protocolsByPort[port], nil
+	}
+	if _, ok := upgradesByProtocol[protocol]; !ok {
+		return "", fmt.Errorf("unsupported starttls protocol %s", protocol)
+	}
+	return protocol, nil
+}
+
+// SMTP, RFC 3207
+func smtpStartTLS(conn net.Conn, _ string) error {
+
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if err := text.PrintfLine("EHLO certinfo"); err != nil {
+		return err
+	}
+	_, extensions, err := text.ReadResponse(250)
+	if err != nil {
+		return fmt.Errorf("ehlo: %w", err)
+	}
+	if !strings.Contains(strings.ToUpper(extensions), "STARTTLS") {
+		return errors.New("server does not support STARTTLS")
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("starttls: %w", err)
+	}
+	return nil
+}
+
+// IMAP, RFC 2595
+func imapStartTLS(conn net.Conn, _ string) error {
+
+	text := textproto.NewConn(conn)
+	greeting, err := text.ReadLine()
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "* OK") {
+		return fmt.Errorf("greeting: %s", greeting)
+	}
+	if err := text.PrintfLine("a001 STARTTLS"); err != nil {
+		return err
+	}
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return fmt.Errorf("starttls: %w", err)
+		}
+		// skip untagged responses
+		if strings.HasPrefix(line, "*") {
+			continue
+		}
+		if !strings.HasPrefix(line, "a001 OK") {
+			return fmt.Errorf("starttls: %s", line)
+		}
+		return nil
+	}
+}
+
+// POP3, RFC 2595
+func pop3StartTLS(conn net.Conn, _ string) error {
+
+	r := bufio.NewReader(conn)
+	greeting, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if !strings.HasPrefix(greeting, "+OK") {
+		return fmt.Errorf("greeting: %s", strings.TrimSpace(greeting))
+	}
+	if _, err := conn.Write([]byte("STLS\r\n")); err != nil {
+		return err
+	}
+	response, err := r.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("stls: %w", err)
+	}
+	if !strings.HasPrefix(response, "+OK") {
+		return fmt.Errorf("stls: %s", strings.TrimSpace(response))
+	}
+	return nil
+}
+
+// XMPP, RFC 6120 client to server stream
+func xmppStartTLS(conn net.Conn, serverName string) error {
+
+	stream := fmt.Sprintf("<?xml version='1.0'?><stream:stream to='%s' xmlns='jabber:client' "+
+		"xmlns:stream='http://etherx.jabber.org/streams' version='1.0'>", serverName)
+	if _, err := conn.Write([]byte(stream)); err != nil {
+		return err
+	}
+	features, err := readUntil(conn, "</stream:features>", "</stream:stream>")
+	if err != nil {
+		return fmt.Errorf("stream features: %w", err)
+	}
+	if !strings.Contains(features, "urn:ietf:params:xml:ns:xmpp-tls") {
+		return errors.New("server does not support STARTTLS")
+	}
+	if _, err := conn.Write([]byte("<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")); err != nil {
+		return err
+	}
+	response, err := readUntil(conn, "<proceed", "<failure")
+	if err != nil {
+		return fmt.Errorf("starttls: %w", err)
+	}
+	if !strings.Contains(response, "<proceed") {
+		return errors.New("starttls: server responded with failure")
+	}
+	return nil
+}
+
+// FTP, RFC 4217
+func ftpStartTLS(conn net.Conn, _ string) error {
+
+	text := textproto.NewConn(conn)
+	if _, _, err := text.ReadResponse(220); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if err := text.PrintfLine("AUTH TLS"); err != nil {
+		return err
+	}
+	if _, _, err := text.ReadResponse(234); err != nil {
+		return fmt.Errorf("auth tls: %w", err)
+	}
+	return nil
+}
+
+// NNTP, RFC 4642
+func nntpStartTLS(conn net.Conn, _ string) error {
+
+	text := textproto.NewConn(conn)
+	// 200 posting allowed, 201 posting prohibited
+	if _, _, err := text.ReadCodeLine(20); err != nil {
+		return fmt.Errorf("greeting: %w", err)
+	}
+	if err := text.PrintfLine("STARTTLS"); err != nil {
+		return err
+	}
+	if _, _, err := text.ReadCodeLine(382); err != nil {
+		return fmt.Errorf("starttls: %w", err)
+	}
+	return nil
+}
+
+// readUntil reads from the connection until any of the markers is found, used for XML streams without line framing
+func readUntil(conn net.Conn, markers ...string) (string, error) {
+
+	var sb strings.Builder
+	buf := make([]byte, 4096)
+	for sb.Len() < maxUpgradeResponseSize {
+		n, err := conn.Read(buf)
+		sb.Write(buf[:n])
+		for _, marker := range markers {
+			if strings.Contains(sb.String(), marker) {
+				return sb.String(), nil
+			}
+		}
+		if err != nil {
+			return sb.String(), err
+		}
+	}
+	return sb.String(), fmt.Errorf("response exceeds %d bytes", maxUpgradeResponseSize)
+}
@@ -0,0 +1,220 @@
+package cert
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/asn1"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificatesFromNetwork_startTLS(t *testing.T) {
+	t.Run("given smtp server then certificates are loaded after starttls", func(t *testing.T) {
+		addr := startTestServer(t, fakeSMTPServer(testServerTLSConfig(t), true))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "smtp", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, uint16(tls.VersionTLS13), location.TLSVersion)
+	})
+
+	t.Run("given smtp server without starttls extension then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, fakeSMTPServer(testServerTLSConfig(t), false))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "smtp", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "server does not support STARTTLS")
+	})
+
+	t.Run("given imap server then certificates are loaded after starttls", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "* OK IMAP4rev1 ready\r\n")
+			line, _ := r.ReadString('\n')
+			tag := strings.Fields(line)[0]
+			fmt.Fprintf(conn, "%s OK Begin TLS negotiation now\r\n", tag)
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "imap", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given pop3 server then certificates are loaded after stls", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "+OK POP3 ready\r\n")
+			r.ReadString('\n')
+			fmt.Fprint(conn, "+OK Begin TLS negotiation\r\n")
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "pop3", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given ldap server then certificates are loaded after starttls extended operation", func(t *testing.T) {
+		addr := startTestServer(t, fakeLDAPServer(testServerTLSConfig(t), 0))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "ldap", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given ldap server responds with error result code then error is returned", func(t *testing.T) {
+		addr := startTestServer(t, fakeLDAPServer(testServerTLSConfig(t), 2))
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "ldap", InsecureSkipVerify: true})
+		assert.ErrorContains(t, location.Error, "result code 2")
+	})
+
+	t.Run("given xmpp server then certificates are loaded after starttls", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		to := make(chan string, 1)
+		addr := startTestServer(t, func(conn net.Conn) {
+			stream, _ := readUntil(conn, "version='1.0'>")
+			if i := strings.Index(stream, "to='"); i != -1 {
+				to <- strings.SplitN(stream[i+4:], "'", 2)[0]
+			}
+			fmt.Fprint(conn, "<?xml version='1.0'?><stream:stream from='certinfo.test' id='1' version='1.0' "+
+				"xmlns='jabber:client' xmlns:stream='http://etherx.jabber.org/streams'><stream:features>"+
+				"<starttls xmlns='urn:ietf:params:xml:ns:xmpp-tls'><required/></starttls></stream:features>")
+			readUntil(conn, "/>")
+			fmt.Fprint(conn, "<proceed xmlns='urn:ietf:params:xml:ns:xmpp-tls'/>")
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "xmpp", ServerName: "certinfo.test", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+		assert.Equal(t, "certinfo.test", <-to)
+	})
+
+	t.Run("given ftp server then certificates are loaded after auth tls", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "220-certinfo.test FTP server\r\n220 ready\r\n")
+			if line, _ := r.ReadString('\n'); line != "AUTH TLS\r\n" {
+				fmt.Fprint(conn, "500 unknown command\r\n")
+				return
+			}
+			fmt.Fprint(conn, "234 AUTH TLS successful\r\n")
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "ftp", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given nntp server then certificates are loaded after starttls", func(t *testing.T) {
+		tlsConfig := testServerTLSConfig(t)
+		addr := startTestServer(t, func(conn net.Conn) {
+			r := bufio.NewReader(conn)
+			fmt.Fprint(conn, "201 certinfo.test NNTP server ready, posting prohibited\r\n")
+			r.ReadString('\n')
+			fmt.Fprint(conn, "382 Continue with TLS negotiation\r\n")
+			tls.Server(conn, tlsConfig).Handshake()
+		})
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{StartTLS: "nntp", InsecureSkipVerify: true})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+		assert.Equal(t, "CN=certinfo.test", location.Certificates[0].SubjectString())
+	})
+
+	t.Run("given unsupported protocol then error is returned", func(t *testing.T) {
+		location := LoadCertificatesFromNetwork(t.Context(), "127.0.0.1:25", NetworkOptions{StartTLS: "gopher"})
+		assert.ErrorContains(t, location.Error, "unsupported starttls protocol gopher")
+	})
+}
+
+func Test_upgradeProtocol(t *testing.T) {
+	tests := []struct {
+		addr     string
+		protocol string
+		expected string
+	}{
+		{addr: "mail.example.com:25", protocol: StartTLSAuto, expected: "smtp"},
+		{addr: "mail.example.com:587", protocol: StartTLSAuto, expected: "smtp"},
+		{addr: "mail.example.com:143", protocol: StartTLSAuto, expected: "imap"},
+		{addr: "mail.example.com:110", protocol: StartTLSAuto, expected: "pop3"},
+		{addr: "mail.example.com:443", protocol: StartTLSAuto, expected: ""},
+		{addr: "mail.example.com:25", protocol: StartTLSNone, expected: ""},
+		{addr: "ldap.example.com:389", protocol: StartTLSAuto, expected: "ldap"},
+		{addr: "mail.example.com:2525", protocol: "smtp", expected: "smtp"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("given %s and %s then %q protocol is used", tt.addr, tt.protocol, tt.expected), func(t *testing.T) {
+			protocol, err := upgradeProtocol(tt.addr, tt.protocol)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, protocol)
+		})
+	}
+}
+
+func fakeSMTPServer(tlsConfig *tls.Config, startTLS bool) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 certinfo.test ESMTP ready\r\n")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch strings.ToUpper(strings.Fields(line)[0]) {
+			case "EHLO":
+				fmt.Fprint(conn, "250-certinfo.test\r\n250-PIPELINING\r\n")
+				if startTLS {
+					fmt.Fprint(conn, "250-STARTTLS\r\n")
+				}
+				fmt.Fprint(conn, "250 8BITMIME\r\n")
+			case "STARTTLS":
+				fmt.Fprint(conn, "220 Ready to start TLS\r\n")
+				tls.Server(conn, tlsConfig).Handshake()
+				return
+			default:
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			}
+		}
+	}
+}
+
+func fakeLDAPServer(tlsConfig *tls.Config, resultCode int) func(conn net.Conn) {
+	return func(conn net.Conn) {
+		request, err := readBERElement(conn, ldapMaxMessageSize)
+		if err != nil {
+			return
+		}
+		var message ldapMessage
+		if _, err := asn1.Unmarshal(request, &message); err != nil {
+			return
+		}
+		var extendedRequest ldapExtendedRequest
+		if _, err := asn1.UnmarshalWithParams(message.ProtocolOp.FullBytes, &extendedRequest, "application,tag:23"); err != nil {
+			return
+		}
+		if string(extendedRequest.RequestName) != ldapStartTLSOID {
+			return
+		}
+
+		extendedResponse, _ := asn1.MarshalWithParams(struct {
+			ResultCode        asn1.Enumerated
+			MatchedDN         []byte
+			DiagnosticMessage []byte
+			ResponseName      []byte `asn1:"tag:10"`
+		}{ResultCode: asn1.Enumerated(resultCode), ResponseName: []byte(ldapStartTLSOID)}, "application,tag:24")
+		response, _ := asn1.Marshal(ldapMessage{MessageID: message.MessageID, ProtocolOp: asn1.RawValue{FullBytes: extendedResponse}})
+		conn.Write(response)
+		if resultCode == 0 {
+			tls.Server(conn, tlsConfig).Handshake()
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cert
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Trust is root and intermediate certificates used for chain verification, zero value uses system roots
+type Trust struct {
+	// Roots is root pool, nil uses system roots
+	Roots *x509.CertPool
+	// Intermediates are added to intermediates sent by the server or found in the location
+	Intermediates []*x509.Certificate
+}
+
+// LoadTrust loads root certificates from CA file and all files in CA directory (files without certificates are
+// skipped) and intermediates from intermediates file, empty file or directory is not loaded. Roots extend system roots,
+// unless replace is set.
+func LoadTrust(caFile, caDir, intermediatesFile string, replace bool) (Trust, error) {
+
+	var trust Trust
+	if caFile != "" || caDir != "" || replace {
+		if caFile == "" && caDir == "" {
+			return Trust{}, errors.New("replacing system roots requires CA file or CA directory")
+		}
+		roots, err := rootPool(replace)
+		if err != nil {
+			return Trust{}, err
+		}
+		trust.Roots = roots
+	}
+
+	if caFile != "" {
+		certificates, err := loadTrustFile(caFile)
+		if err != nil {
+			return Trust{}, err
+		}
+		for _, certificate := range certificates {
+			trust.Roots.AddCert(certificate)
+		}
+	}
+	if caDir != "" {
+		entries, err := os.ReadDir(caDir)
+		if err != nil {
+			return Trust{}, fmt.Errorf("read CA directory: %w", err)
+		}
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			certificates, err := loadTrustFile(filepath.Join(caDir, entry.Name()))
+			if err != nil {
+				slog.Debug(fmt.Sprintf("skipping CA directory file %s: %v", entry.Name(), err))
+				continue
+			}
+			for _, certificate := range certificates {
+				trust.Roots.AddCert(certificate)
+			}
+		}
+	}
+
+	if intermediatesFile != "" {
+		certificates, err := loadTrustFile(intermediatesFile)
+		if err != nil {
+			return Trust{}, err
+		}
+		trust.Intermediates = certificates
+	}
+	return trust, nil
+}
+
+func rootPool(replace bool) (*x509.CertPool, error) {
+
+	if replace {
+		return x509.NewCertPool(), nil
+	}
+	return x509.SystemCertPool()
+}
+
+// loadTrustFile loads certificates from PEM, DER or PKCS#7 file, file has to contain at least one certificate and all
+// certificates have to be valid
+func loadTrustFile(fileName string) ([]*x509.Certificate, error) {
+
+	b, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	certificates, err := FromBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	var out []*x509.Certificate
+	for _, certificate := range certificates {
+		if err := certificate.Error(); err != nil {
+			return nil, fmt.Errorf("%s: %w", fileName, err)
+		}
+		out = append(out, certificate.x509Certificate)
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("%s: no certificates found", fileName)
+	}
+	return out, nil
+}
+
+func (t Trust) roots() (*x509.CertPool, error) {
+
+	if t.Roots != nil {
+		return t.Roots, nil
+	}
+	return x509.SystemCertPool()
+}
+
+// verifyConnection verifies server certificate chain and server name (host name or IP address, connection state does
+// not have it if SNI is not sent) with trust intermediates, it replaces TLS verification, because TLS verification
+// uses only intermediates sent by the server
+func (t Trust) verifyConnection(state tls.ConnectionState, serverName string) error {
+
+	if len(state.PeerCertificates) == 0 {
+		return errors.New("tls: server did not send any certificate")
+	}
+	roots, err := t.roots()
+	if err != nil {
+		return err
+	}
+	opts := x509.VerifyOptions{
+		DNSName:       serverName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, certificate := range slices.Concat(state.PeerCertificates[1:], t.Intermediates) {
+		opts.Intermediates.AddCert(certificate)
+	}
+	if _, err := state.PeerCertificates[0].Verify(opts); err != nil {
+		return &tls.CertificateVerificationError{UnverifiedCertificates: state.PeerCertificates, Err: err}
+	}
+	return nil
+}
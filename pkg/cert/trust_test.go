@@ -0,0 +1,196 @@
+package cert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTrust(t *testing.T) {
+	pki := newTestPKI(t)
+
+	t.Run("given no files then system roots are used", func(t *testing.T) {
+		trust, err := LoadTrust("", "", "", false)
+		require.NoError(t, err)
+		assert.Nil(t, trust.Roots)
+		assert.Empty(t, trust.Intermediates)
+	})
+
+	t.Run("given ca file and replace then only ca file roots are used", func(t *testing.T) {
+		caFile := writeTestPEM(t, t.TempDir(), "ca.pem", pki.root)
+		trust, err := LoadTrust(caFile, "", "", true)
+		require.NoError(t, err)
+		require.NotNil(t, trust.Roots)
+		assert.True(t, trust.Roots.Equal(poolOf(pki.root)))
+	})
+
+	t.Run("given ca directory then certificate files are loaded and other files are skipped", func(t *testing.T) {
+		dir := t.TempDir()
+		writeTestPEM(t, dir, "ca.pem", pki.root)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README"), []byte("not a certificate"), 0600))
+		trust, err := LoadTrust("", dir, "", true)
+		require.NoError(t, err)
+		assert.True(t, trust.Roots.Equal(poolOf(pki.root)))
+	})
+
+	t.Run("given intermediates file then intermediates are loaded", func(t *testing.T) {
+		intermediatesFile := writeTestPEM(t, t.TempDir(), "intermediates.pem", pki.intermediate)
+		trust, err := LoadTrust("", "", intermediatesFile, false)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(trust.Intermediates))
+		assert.Equal(t, pki.intermediate.Raw, trust.Intermediates[0].Raw)
+	})
+
+	t.Run("given replace without ca file or directory then error is returned", func(t *testing.T) {
+		_, err := LoadTrust("", "", "", true)
+		assert.EqualError(t, err, "replacing system roots requires CA file or CA directory")
+	})
+
+	t.Run("given ca file without certificates then error is returned", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		require.NoError(t, os.WriteFile(caFile, []byte("not a certificate"), 0600))
+		_, err := LoadTrust(caFile, "", "", false)
+		assert.Error(t, err)
+	})
+}
+
+func TestCertificateLocation_Chains(t *testing.T) {
+	pki := newTestPKI(t)
+	location := CertificateLocation{Path: "leaf.pem", Certificates: FromX509Certificates([]*x509.Certificate{pki.leaf.Leaf})}
+
+	t.Run("given trust roots and intermediates then chain is verified", func(t *testing.T) {
+		chains, err := location.Chains(Trust{Roots: poolOf(pki.root), Intermediates: []*x509.Certificate{pki.intermediate}})
+		require.NoError(t, err)
+		require.Equal(t, 1, len(chains))
+		require.Equal(t, 3, len(chains[0]))
+		assert.Equal(t, "CN=certinfo test root", chains[0][2].SubjectString())
+	})
+
+	t.Run("given trust roots without intermediates then error is returned", func(t *testing.T) {
+		_, err := location.Chains(Trust{Roots: poolOf(pki.root)})
+		assert.ErrorContains(t, err, "certificate signed by unknown authority")
+	})
+}
+
+func TestLoadCertificatesFromNetwork_trust(t *testing.T) {
+	pki := newTestPKI(t)
+	addr := startTestServer(t, func(conn net.Conn) {
+		tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{pki.leaf}}).Handshake()
+	})
+	_, port, err := net.SplitHostPort(addr)
+	require.NoError(t, err)
+	hostAddr := "certinfo.test:" + port
+
+	t.Run("given trust roots and intermediates then server certificate is verified", func(t *testing.T) {
+		trust := Trust{Roots: poolOf(pki.root), Intermediates: []*x509.Certificate{pki.intermediate}}
+		location := LoadCertificatesFromNetwork(t.Context(), hostAddr, NetworkOptions{IP: "127.0.0.1", Trust: trust})
+		require.NoError(t, location.Error)
+		require.Equal(t, 1, len(location.Certificates))
+	})
+
+	t.Run("given trust roots without intermediates then verification fails", func(t *testing.T) {
+		location := LoadCertificatesFromNetwork(t.Context(), hostAddr, NetworkOptions{IP: "127.0.0.1", Trust: Trust{Roots: poolOf(pki.root)}})
+		assert.ErrorContains(t, location.Error, "certificate signed by unknown authority")
+	})
+
+	t.Run("given trust intermediates and wrong host then verification fails", func(t *testing.T) {
+		trust := Trust{Roots: poolOf(pki.root), Intermediates: []*x509.Certificate{pki.intermediate}}
+		location := LoadCertificatesFromNetwork(t.Context(), addr, NetworkOptions{Trust: trust})
+		assert.ErrorContains(t, location.Error, "cannot validate certificate for 127.0.0.1")
+	})
+}
+
+type testPKI struct {
+	root            *x509.Certificate
+	intermediate    *x509.Certificate
+	intermediateKey *ecdsa.PrivateKey
+	leaf            tls.Certificate
+}
+
+// newTestPKI returns root and intermediate CA and leaf certificate for certinfo.test issued by the intermediate
+func newTestPKI(t *testing.T) testPKI {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	root := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "certinfo test root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, rootKey, rootKey)
+
+	intermediateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	intermediate := createTestCertificate(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "certinfo test intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, root, intermediateKey, rootKey)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leaf := createTestCertificate(t, &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "certinfo.test"},
+		DNSNames:     []string{"certinfo.test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}, intermediate, leafKey, intermediateKey)
+
+	return testPKI{
+		root:            root,
+		intermediate:    intermediate,
+		intermediateKey: intermediateKey,
+		leaf:            tls.Certificate{Certificate: [][]byte{leaf.Raw}, PrivateKey: leafKey, Leaf: leaf},
+	}
+}
+
+// createTestCertificate creates certificate signed by parent, self-signed if parent is nil
+func createTestCertificate(t *testing.T, template, parent *x509.Certificate, key, parentKey *ecdsa.PrivateKey) *x509.Certificate {
+	if parent == nil {
+		parent = template
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, key.Public(), parentKey)
+	require.NoError(t, err)
+	certificate, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return certificate
+}
+
+func writeTestPEM(t *testing.T, dir, name string, certificates ...*x509.Certificate) string {
+	var b []byte
+	for _, certificate := range certificates {
+		b = append(b, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw})...)
+	}
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(file, b, 0600))
+	return file
+}
+
+func poolOf(certificates ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, certificate := range certificates {
+		pool.AddCert(certificate)
+	}
+	return pool
+}
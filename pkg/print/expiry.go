@@ -3,6 +3,8 @@ package print
 import (
 	"fmt"
 	"github.com/pete911/certinfo/pkg/cert"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 )
@@ -11,24 +13,65 @@ func Expiry(certificateLocations []cert.CertificateLocation) {
 
 	for _, certificateLocation := range certificateLocations {
 		if certificateLocation.Error != nil {
-			fmt.Printf("--- [%s: %v] ---\n", certificateLocation.Name(), certificateLocation.Error)
+			fmt.Println(errorHeader(certificateLocation))
+			printLabels(certificateLocation)
+			printClientAuth(certificateLocation)
+			printKeyExchange(certificateLocation)
 			fmt.Println()
 			continue
 		}
 
 		fmt.Printf("--- [%s] ---\n", certificateLocation.Name())
+		printCertificateMismatch(certificateLocation)
+		printClientAuth(certificateLocation)
+		printOCSPStaple(certificateLocation)
+		printKeyExchange(certificateLocation)
+		printInventory(certificateLocation)
 		for _, certificate := range certificateLocation.Certificates {
 
+			if certificate.Alias() != "" {
+				fmt.Printf("Alias: %s\n", certificate.Alias())
+			}
 			fmt.Printf("Subject: %s\n", certificate.SubjectString())
 			if len(certificate.DNSNames()) != 0 {
 				fmt.Printf("DNS Names: %s\n", strings.Join(certificate.DNSNames(), ", "))
 			}
 			fmt.Printf("Expiry: %s\n", expiryString(certificate))
+			if revocation := revocationString(certificate); revocation != "" {
+				fmt.Printf("OCSP: %s\n", revocation)
+			}
 			fmt.Println()
 		}
 	}
 }
 
+// ExpiryByLabel prints expiry of certificates grouped by label value, groups are sorted by value and locations without
+// the label are printed last
+func ExpiryByLabel(certificateLocations []cert.CertificateLocation, label string) {
+
+	groups := make(map[string][]cert.CertificateLocation)
+	var unlabeled []cert.CertificateLocation
+	for _, certificateLocation := range certificateLocations {
+		value, ok := certificateLocation.Labels[label]
+		if !ok {
+			unlabeled = append(unlabeled, certificateLocation)
+			continue
+		}
+		groups[value] = append(groups[value], certificateLocation)
+	}
+
+	for _, value := range slices.Sorted(maps.Keys(groups)) {
+		fmt.Printf("=== [%s=%s] ===\n", label, value)
+		fmt.Println()
+		Expiry(groups[value])
+	}
+	if len(unlabeled) != 0 {
+		fmt.Printf("=== [%s not set] ===\n", label)
+		fmt.Println()
+		Expiry(unlabeled)
+	}
+}
+
 func expiryString(certificate cert.Certificate) string {
 
 	if certificate.Error() != nil {
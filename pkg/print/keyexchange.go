@@ -0,0 +1,44 @@
+package print
+
+import (
+	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
+)
+
+// KeyExchangeSummary prints post-quantum key exchange readiness of all probed network locations, locations that were
+// not probed (files) are skipped and locations where the probe failed are listed separately
+func KeyExchangeSummary(certificateLocations []cert.CertificateLocation) {
+
+	var supported, notSupported, failed []cert.CertificateLocation
+	for _, certificateLocation := range certificateLocations {
+		switch {
+		case certificateLocation.KeyExchangeError != nil:
+			failed = append(failed, certificateLocation)
+		case certificateLocation.KeyExchange == nil:
+			continue
+		case certificateLocation.KeyExchange.PostQuantum():
+			supported = append(supported, certificateLocation)
+		default:
+			notSupported = append(notSupported, certificateLocation)
+		}
+	}
+
+	fmt.Println("=== [post-quantum key exchange summary] ===")
+	fmt.Printf("Endpoints: %d\n", len(supported)+len(notSupported)+len(failed))
+	printKeyExchangeGroup("Supported", supported)
+	printKeyExchangeGroup("Not Supported", notSupported)
+	printKeyExchangeGroup("Failed", failed)
+	fmt.Println()
+}
+
+func printKeyExchangeGroup(title string, certificateLocations []cert.CertificateLocation) {
+
+	fmt.Printf("%s: %d\n", title, len(certificateLocations))
+	for _, certificateLocation := range certificateLocations {
+		if certificateLocation.KeyExchangeError != nil {
+			fmt.Printf("    %s: %v\n", certificateLocation.Name(), certificateLocation.KeyExchangeError)
+			continue
+		}
+		fmt.Printf("    %s\n", certificateLocation.Name())
+	}
+}
@@ -4,25 +4,34 @@ import (
 	"fmt"
 	"github.com/pete911/certinfo/pkg/cert"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 )
 
-func Locations(certificateLocations []cert.CertificateLocation, printChains, printPem, printExtensions, printSignature bool) {
+func Locations(certificateLocations []cert.CertificateLocation, trust cert.Trust, printChains, printPem, printExtensions, printSignature bool) {
 
 	for _, certificateLocation := range certificateLocations {
 		if certificateLocation.Error != nil {
-			slog.Error(fmt.Sprintf("%s: %v", certificateLocation.Name(), certificateLocation.Error))
-			fmt.Printf("--- [%s: %v] ---\n", certificateLocation.Name(), certificateLocation.Error)
+			fmt.Println(errorHeader(certificateLocation))
+			printLabels(certificateLocation)
+			printClientAuth(certificateLocation)
+			printKeyExchange(certificateLocation)
 			fmt.Println()
 			continue
 		}
 
-		fmt.Printf("--- [%s] ---\n", certificateLocation.Name())
+		printLocationHeader(certificateLocation)
+		printCertificateMismatch(certificateLocation)
+		printClientAuth(certificateLocation)
+		printOCSPStaple(certificateLocation)
+		printKeyExchange(certificateLocation)
+		printInventory(certificateLocation)
 		printCertificates(certificateLocation.Certificates, printPem, printExtensions, printSignature)
+		printPrivateKeys(certificateLocation.PrivateKeys)
 
 		if printChains {
-			chains, err := certificateLocation.Chains()
+			chains, err := certificateLocation.Chains(trust)
 			if err != nil {
 				slog.Error(fmt.Sprintf("chains for %s: %v", certificateLocation.Name(), certificateLocation.Error))
 				fmt.Printf("--- [chains for %s: %v] ---\n", certificateLocation.Name(), err)
@@ -42,6 +51,203 @@ func Locations(certificateLocations []cert.CertificateLocation, printChains, pri
 	}
 }
 
+// errorHeader returns header of location that could not be loaded, cancelled location (interrupted or run deadline
+// exceeded before it was loaded) did not fail, so it has its own status
+func errorHeader(certificateLocation cert.CertificateLocation) string {
+
+	if certificateLocation.Cancelled() {
+		cause := strings.TrimPrefix(certificateLocation.Error.Error(), cert.ErrCancelled.Error()+": ")
+		return fmt.Sprintf("--- [%s - Cancelled (%s)] ---", certificateLocation.Name(), cause)
+	}
+	return fmt.Sprintf("--- [%s: %v] ---", certificateLocation.Name(), certificateLocation.Error)
+}
+
+// printLocationHeader prints location name with TLS handshake parameters for network locations
+func printLocationHeader(certificateLocation cert.CertificateLocation) {
+
+	if handshake := certificateLocation.Handshake(); handshake != "" {
+		fmt.Printf("--- [%s, %s] ---\n", certificateLocation.Name(), handshake)
+		return
+	}
+	fmt.Printf("--- [%s] ---\n", certificateLocation.Name())
+}
+
+// printInventory prints labels and expected hostnames set by targets inventory
+func printInventory(certificateLocation cert.CertificateLocation) {
+
+	if len(certificateLocation.Labels) == 0 && len(certificateLocation.ExpectedHostnames) == 0 {
+		return
+	}
+	printLabels(certificateLocation)
+	unmatched := certificateLocation.UnmatchedHostnames()
+	for _, hostname := range certificateLocation.ExpectedHostnames {
+		if slices.Contains(unmatched, hostname) {
+			fmt.Printf("Expected Hostname: %s - Not Matched!\n", hostname)
+			continue
+		}
+		fmt.Printf("Expected Hostname: %s\n", hostname)
+	}
+	fmt.Println()
+}
+
+// printCertificateMismatch prints warning if the location (IP) serves different certificate than other IPs of the host
+func printCertificateMismatch(certificateLocation cert.CertificateLocation) {
+
+	if certificateLocation.CertificateMismatch {
+		fmt.Println("Leaf Certificate: Different from other IPs of the host!")
+		fmt.Println()
+	}
+}
+
+// printClientAuth prints client certificate request and CAs the server accepts client certificates from
+func printClientAuth(certificateLocation cert.CertificateLocation) {
+
+	clientAuth := certificateLocation.ClientAuth
+	if clientAuth == nil {
+		return
+	}
+	if clientAuth.CertificateSent {
+		fmt.Println("Client Certificate: Requested (sent)")
+	} else {
+		fmt.Println("Client Certificate: Requested (not sent)")
+	}
+	if len(clientAuth.AcceptableCAs) == 0 {
+		fmt.Println("Acceptable Client CAs: any")
+	} else {
+		fmt.Println("Acceptable Client CAs:")
+		for _, ca := range clientAuth.AcceptableCAs {
+			fmt.Printf("    %s\n", ca)
+		}
+	}
+	if certificateLocation.Error == nil {
+		fmt.Println()
+	}
+}
+
+// printOCSPStaple prints OCSP response stapled by the server and warns if must-staple certificate is not stapled,
+// nothing is printed if the server did not staple response and the certificate does not require it
+func printOCSPStaple(certificateLocation cert.CertificateLocation) {
+
+	switch {
+	case certificateLocation.OCSPStapleError != nil:
+		fmt.Printf("OCSP Staple: %v\n", certificateLocation.OCSPStapleError)
+	case certificateLocation.OCSPStaple != nil:
+		printOCSPResponse("OCSP Staple", *certificateLocation.OCSPStaple)
+	case certificateLocation.MissingStaple():
+		fmt.Println("OCSP Staple: Missing, certificate requires OCSP stapling (must-staple)!")
+	default:
+		return
+	}
+	fmt.Println()
+}
+
+func printOCSPResponse(title string, response cert.OCSPResponse) {
+
+	if response.Status == cert.OCSPStatusRevoked {
+		fmt.Printf("%s: %s - Revoked!\n", title, response.Status)
+	} else {
+		fmt.Printf("%s: %s\n", title, response.Status)
+	}
+	fmt.Printf("    Responder  : %s\n", response.Responder)
+	fmt.Printf("    This Update: %s\n", validityFormat(response.ThisUpdate))
+	switch {
+	case response.NextUpdate.IsZero():
+		fmt.Println("    Next Update: -")
+	case response.IsExpired():
+		fmt.Printf("    Next Update: %s - Expired!\n", validityFormat(response.NextUpdate))
+	default:
+		fmt.Printf("    Next Update: %s\n", validityFormat(response.NextUpdate))
+	}
+	if response.Status == cert.OCSPStatusRevoked {
+		fmt.Printf("    Revoked At : %s\n", validityFormat(response.RevokedAt))
+		if response.RevocationReason != "" {
+			fmt.Printf("    Reason     : %s\n", response.RevocationReason)
+		}
+	}
+	if response.SignatureError != nil {
+		fmt.Printf("    Signature  : Invalid, %v!\n", response.SignatureError)
+	} else {
+		fmt.Println("    Signature  : valid")
+	}
+}
+
+// printRevocation prints OCSP response of online revocation check, nothing is printed if revocation was not checked
+func printRevocation(certificate cert.Certificate) {
+
+	revocation := certificate.Revocation()
+	if revocation == nil {
+		return
+	}
+	if revocation.Error != nil {
+		fmt.Printf("OCSP: %v\n", revocation.Error)
+		return
+	}
+	printOCSPResponse("OCSP", revocation.Response)
+	fmt.Printf("    URL        : %s\n", revocation.Responder)
+}
+
+// revocationString returns OCSP certificate status of online revocation check, empty if revocation was not checked
+func revocationString(certificate cert.Certificate) string {
+
+	revocation := certificate.Revocation()
+	if revocation == nil {
+		return ""
+	}
+	if revocation.Error != nil {
+		return revocation.Error.Error()
+	}
+	response := revocation.Response
+	status := response.Status
+	if response.Status == cert.OCSPStatusRevoked {
+		revoked := validityFormat(response.RevokedAt)
+		if response.RevocationReason != "" {
+			revoked = fmt.Sprintf("%s, %s", revoked, response.RevocationReason)
+		}
+		status = fmt.Sprintf("%s - Revoked! (%s)", status, revoked)
+	}
+	if response.SignatureError != nil {
+		status = fmt.Sprintf("%s, Invalid signature, %v!", status, response.SignatureError)
+	}
+	return status
+}
+
+// printKeyExchange prints post-quantum readiness and key exchange groups accepted by the server, it is printed for
+// locations with handshake error as well
+func printKeyExchange(certificateLocation cert.CertificateLocation) {
+
+	if certificateLocation.KeyExchangeError != nil {
+		fmt.Printf("Post-Quantum Key Exchange: %v\n", certificateLocation.KeyExchangeError)
+		if certificateLocation.Error == nil {
+			fmt.Println()
+		}
+		return
+	}
+	keyExchange := certificateLocation.KeyExchange
+	if keyExchange == nil {
+		return
+	}
+	if keyExchange.PostQuantum() {
+		fmt.Println("Post-Quantum Key Exchange: supported")
+	} else {
+		fmt.Println("Post-Quantum Key Exchange: not supported")
+	}
+	if groups := keyExchange.Groups(); len(groups) != 0 {
+		fmt.Printf("Key Exchange Groups: %s\n", strings.Join(groups, ", "))
+	} else {
+		fmt.Println("Key Exchange Groups: none")
+	}
+	if certificateLocation.Error == nil {
+		fmt.Println()
+	}
+}
+
+func printLabels(certificateLocation cert.CertificateLocation) {
+
+	if len(certificateLocation.Labels) != 0 {
+		fmt.Printf("Labels: %s\n", certificateLocation.LabelsString())
+	}
+}
+
 func printCertificates(certs cert.Certificates, printPem, printExtensions, printSignature bool) {
 
 	for _, certificate := range certs {
@@ -53,6 +259,20 @@ func printCertificates(certs cert.Certificates, printPem, printExtensions, print
 	}
 }
 
+func printPrivateKeys(privateKeys []cert.PrivateKey) {
+
+	for _, privateKey := range privateKeys {
+		if privateKey.MatchesLeaf {
+			fmt.Printf("Private Key: %s (matches leaf certificate)\n", privateKey.Algorithm)
+		} else {
+			fmt.Printf("Private Key: %s (does not match leaf certificate)\n", privateKey.Algorithm)
+		}
+	}
+	if len(privateKeys) != 0 {
+		fmt.Println()
+	}
+}
+
 func printCertificate(certificate cert.Certificate, printExtensions, printSignature bool) {
 
 	if certificate.Error() != nil {
@@ -61,6 +281,9 @@ func printCertificate(certificate cert.Certificate, printExtensions, printSignat
 		return
 	}
 
+	if certificate.Alias() != "" {
+		fmt.Printf("Alias: %s\n", certificate.Alias())
+	}
 	fmt.Printf("Version: %d\n", certificate.Version())
 	fmt.Printf("Serial Number: %s\n", certificate.SerialNumber())
 	fmt.Printf("Signature Algorithm: %s\n", certificate.SignatureAlgorithm())
@@ -79,6 +302,7 @@ func printCertificate(certificate cert.Certificate, printExtensions, printSignat
 	fmt.Printf("Key Usage: %s\n", strings.Join(certificate.KeyUsage(), ", "))
 	fmt.Printf("Ext Key Usage: %s\n", strings.Join(certificate.ExtKeyUsage(), ", "))
 	fmt.Printf("CA: %t\n", certificate.IsCA())
+	printRevocation(certificate)
 
 	if printExtensions {
 		fmt.Println("Extensions:")
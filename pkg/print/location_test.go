@@ -1,6 +1,9 @@
 package print
 
 import (
+	"errors"
+	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -25,3 +28,16 @@ func Test_splitString(t *testing.T) {
 		assert.Equal(t, len(someString)+2, len(out[0]))
 	})
 }
+
+func Test_errorHeader(t *testing.T) {
+	t.Run("given location failed then error is in header", func(t *testing.T) {
+		location := cert.CertificateLocation{Path: "example.com:443", Error: errors.New("connection refused")}
+		assert.Equal(t, "--- [example.com:443: connection refused] ---", errorHeader(location))
+	})
+
+	t.Run("given location is cancelled then header has cancelled status", func(t *testing.T) {
+		err := fmt.Errorf("%w: %w", cert.ErrCancelled, errors.New("run deadline exceeded"))
+		location := cert.CertificateLocation{Path: "example.com:443", Error: err}
+		assert.Equal(t, "--- [example.com:443 - Cancelled (run deadline exceeded)] ---", errorHeader(location))
+	})
+}
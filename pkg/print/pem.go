@@ -6,15 +6,23 @@ import (
 	"log/slog"
 )
 
-func Pem(certificateLocations []cert.CertificateLocation, printChains bool) {
+func Pem(certificateLocations []cert.CertificateLocation, trust cert.Trust, printChains bool) {
 
 	for _, certificateLocation := range certificateLocations {
+		if certificateLocation.Error != nil {
+			// load error is logged by the caller, PEM output contains only certificates
+			continue
+		}
+		// text outside of PEM blocks is ignored by parsers
+		if len(certificateLocation.Labels) != 0 {
+			fmt.Printf("# %s: %s\n", certificateLocation.Path, certificateLocation.LabelsString())
+		}
 		for _, certificate := range certificateLocation.Certificates {
 			fmt.Print(string(certificate.ToPEM()))
 		}
 
 		if printChains {
-			chains, err := certificateLocation.Chains()
+			chains, err := certificateLocation.Chains(trust)
 			if err != nil {
 				slog.Error(fmt.Sprintf("chains: %v", err))
 				continue
@@ -0,0 +1,69 @@
+package print
+
+import (
+	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
+	"strings"
+)
+
+// Scan prints TLS versions and cipher suites accepted by network locations with the grade, locations without scan
+// (files) are printed with location header only. Scan is printed for locations with handshake error as well.
+func Scan(certificateLocations []cert.CertificateLocation) {
+
+	for _, certificateLocation := range certificateLocations {
+		scanned := certificateLocation.Scan != nil || certificateLocation.ScanError != nil
+		if certificateLocation.Error != nil {
+			fmt.Println(errorHeader(certificateLocation))
+			printLabels(certificateLocation)
+			if !scanned {
+				fmt.Println()
+				continue
+			}
+		} else {
+			printLocationHeader(certificateLocation)
+			printLabels(certificateLocation)
+		}
+		printKeyExchange(certificateLocation)
+		switch {
+		case certificateLocation.ScanError != nil:
+			fmt.Printf("Scan: %v\n", certificateLocation.ScanError)
+		case certificateLocation.Scan == nil:
+			fmt.Println("Scan: not applicable")
+		default:
+			printTLSScan(*certificateLocation.Scan)
+		}
+		fmt.Println()
+	}
+}
+
+func printTLSScan(scan cert.TLSScan) {
+
+	grade, reasons := scan.Grade()
+	if len(reasons) == 0 {
+		fmt.Printf("Grade: %s\n", grade)
+	} else {
+		fmt.Printf("Grade: %s (%s)\n", grade, strings.Join(reasons, ", "))
+	}
+	fmt.Printf("Server Cipher Preference: %s\n", serverPreferenceString(scan.ServerPreference))
+	for _, version := range scan.Versions {
+		if !version.Accepted {
+			fmt.Printf("%s: not accepted\n", version.VersionString())
+			continue
+		}
+		fmt.Printf("%s: accepted\n", version.VersionString())
+		for _, cipherSuite := range version.CipherSuiteStrings() {
+			fmt.Printf("    %s\n", cipherSuite)
+		}
+	}
+}
+
+func serverPreferenceString(preference *bool) string {
+
+	if preference == nil {
+		return "unknown"
+	}
+	if *preference {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+type PoolOptions struct {
+	// maximum number of inputs loaded in parallel
+	Concurrency int
+	// maximum number of parallel connections to the same host, 0 is no limit
+	PerHost int
+	// number of retries of transient connect errors, backoff doubles with every retry
+	Retries      int
+	RetryBackoff time.Duration
+	// maximum number of new connections per second, 0 is no limit
+	RequestsPerSecond float64
+}
+
+// pool limits concurrency of loading inputs and network connections
+type pool struct {
+	options PoolOptions
+	limiter *rateLimiter
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newPool(options PoolOptions) *pool {
+
+	p := &pool{options: options, hosts: make(map[string]chan struct{})}
+	if options.RequestsPerSecond > 0 {
+		p.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / options.RequestsPerSecond)}
+	}
+	return p
+}
+
+// run calls fn for every index from 0 to n-1, at most concurrency calls run in parallel
+func (p *pool) run(n int, fn func(i int)) {
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(p.options.Concurrency, 1), n) {
+		wg.Go(func() {
+			for i := range indexes {
+				fn(i)
+			}
+		})
+	}
+	for i := range n {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
+
+// dial calls load with per host limit and rate limit, load is retried if it fails with transient connect error. Load
+// is called with done context as well, so it can report the location as cancelled.
+func (p *pool) dial(ctx context.Context, host string, load func() cert.CertificateLocation) cert.CertificateLocation {
+
+	release := p.acquireHost(ctx, host)
+	defer release()
+
+	backoff := p.options.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		p.limiter.wait(ctx)
+		location := load()
+		if location.Error == nil || attempt >= p.options.Retries || !isTransientError(location.Error) {
+			return location
+		}
+		slog.Debug(fmt.Sprintf("retrying %s in %s: %v", location.Path, backoff, location.Error))
+		if !sleep(ctx, backoff) {
+			return load()
+		}
+		backoff *= 2
+	}
+}
+
+// acquireHost blocks until there is less than per host limit connections to the host (or the context is done),
+// returned function releases it
+func (p *pool) acquireHost(ctx context.Context, host string) func() {
+
+	if p.options.PerHost <= 0 {
+		return func() {}
+	}
+	p.mu.Lock()
+	semaphore, ok := p.hosts[host]
+	if !ok {
+		semaphore = make(chan struct{}, p.options.PerHost)
+		p.hosts[host] = semaphore
+	}
+	p.mu.Unlock()
+
+	select {
+	case semaphore <- struct{}{}:
+		return func() { <-semaphore }
+	case <-ctx.Done():
+		return func() {}
+	}
+}
+
+// isTransientError checks if the connection failed to be established and can succeed on retry (connect timeouts,
+// refused or reset connections and temporary DNS failures). Errors after the connection is established (protocol
+// upgrade and TLS handshake) are not retried, server that accepts connection but does not respond would time out again.
+func isTransientError(err error) bool {
+
+	if errors.Is(err, cert.ErrCancelled) {
+		return false
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// rateLimiter spaces calls by interval, nil rate limiter does not limit
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// wait blocks until the next call is allowed or the context is done
+func (r *rateLimiter) wait(ctx context.Context) {
+
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	delay := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	r.mu.Unlock()
+
+	sleep(ctx, delay)
+}
+
+// sleep pauses for the duration, returns false if the context is done before the duration elapsed
+func sleep(ctx context.Context, d time.Duration) bool {
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
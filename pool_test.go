@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/pete911/certinfo/pkg/cert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPool_run(t *testing.T) {
+
+	t.Run("given concurrency then at most concurrency calls run in parallel", func(t *testing.T) {
+
+		var running, maxRunning atomic.Int32
+		results := make([]int, 20)
+		newPool(PoolOptions{Concurrency: 3}).run(len(results), func(i int) {
+			n := running.Add(1)
+			defer running.Add(-1)
+			storeMax(&maxRunning, n)
+			time.Sleep(5 * time.Millisecond)
+			results[i] = i
+		})
+		assert.LessOrEqual(t, maxRunning.Load(), int32(3))
+		for i, v := range results {
+			assert.Equal(t, i, v)
+		}
+	})
+}
+
+func TestPool_dial(t *testing.T) {
+
+	t.Run("given per host limit then at most per host connections run in parallel", func(t *testing.T) {
+
+		p := newPool(PoolOptions{PerHost: 2})
+		var running, maxRunning atomic.Int32
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Go(func() {
+				p.dial(t.Context(), "example.com", func() cert.CertificateLocation {
+					n := running.Add(1)
+					defer running.Add(-1)
+					storeMax(&maxRunning, n)
+					time.Sleep(5 * time.Millisecond)
+					return cert.CertificateLocation{}
+				})
+			})
+		}
+		wg.Wait()
+		assert.Equal(t, int32(2), maxRunning.Load())
+	})
+
+	t.Run("given transient error then load is retried", func(t *testing.T) {
+
+		p := newPool(PoolOptions{Retries: 2, RetryBackoff: time.Millisecond})
+		var attempts int
+		location := p.dial(t.Context(), "example.com", func() cert.CertificateLocation {
+			attempts++
+			if attempts < 3 {
+				return cert.CertificateLocation{Path: "example.com:443", Error: dialError(syscall.ECONNREFUSED)}
+			}
+			return cert.CertificateLocation{Path: "example.com:443"}
+		})
+		assert.NoError(t, location.Error)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("given transient error and no retries left then error is returned", func(t *testing.T) {
+
+		p := newPool(PoolOptions{Retries: 1, RetryBackoff: time.Millisecond})
+		var attempts int
+		location := p.dial(t.Context(), "example.com", func() cert.CertificateLocation {
+			attempts++
+			return cert.CertificateLocation{Path: "example.com:443", Error: dialError(syscall.ECONNRESET)}
+		})
+		assert.Error(t, location.Error)
+		assert.Equal(t, 2, attempts)
+	})
+
+	t.Run("given non transient error then load is not retried", func(t *testing.T) {
+
+		p := newPool(PoolOptions{Retries: 2, RetryBackoff: time.Millisecond})
+		var attempts int
+		p.dial(t.Context(), "example.com", func() cert.CertificateLocation {
+			attempts++
+			return cert.CertificateLocation{Path: "example.com:443", Error: errors.New("tls: failed to verify certificate")}
+		})
+		assert.Equal(t, 1, attempts)
+	})
+
+	t.Run("given context is cancelled during backoff then load is not retried", func(t *testing.T) {
+
+		p := newPool(PoolOptions{Retries: 5, RetryBackoff: time.Hour})
+		ctx, cancel := context.WithCancel(t.Context())
+		var attempts int
+		location := p.dial(ctx, "example.com", func() cert.CertificateLocation {
+			attempts++
+			if attempts == 1 {
+				cancel()
+				return cert.CertificateLocation{Path: "example.com:443", Error: dialError(syscall.ECONNREFUSED)}
+			}
+			return cert.CertificateLocation{Path: "example.com:443", Error: cert.ErrCancelled}
+		})
+		assert.Equal(t, 2, attempts)
+		assert.True(t, location.Cancelled())
+	})
+}
+
+func Test_isTransientError(t *testing.T) {
+
+	assert.True(t, isTransientError(dialError(syscall.ECONNREFUSED)))
+	assert.True(t, isTransientError(fmt.Errorf("proxy socks5://proxy:1080: %w", dialError(syscall.ECONNRESET))))
+	assert.True(t, isTransientError(dialError(os.ErrDeadlineExceeded)))
+	assert.False(t, isTransientError(fmt.Errorf("smtp starttls: %w", readError(syscall.ECONNRESET))))
+	assert.False(t, isTransientError(readError(os.ErrDeadlineExceeded)))
+	assert.True(t, isTransientError(&net.DNSError{Err: "server misbehaving", IsTemporary: true}))
+	assert.False(t, isTransientError(&net.DNSError{Err: "no such host", IsNotFound: true}))
+	assert.False(t, isTransientError(errors.New("x509: certificate signed by unknown authority")))
+	assert.False(t, isTransientError(fmt.Errorf("%w: %w", cert.ErrCancelled, dialError(os.ErrDeadlineExceeded))))
+}
+
+func Test_rateLimiter(t *testing.T) {
+
+	t.Run("given rate limiter then calls are spaced by interval", func(t *testing.T) {
+
+		limiter := &rateLimiter{interval: 20 * time.Millisecond}
+		start := time.Now()
+		for range 3 {
+			limiter.wait(t.Context())
+		}
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("given nil rate limiter then calls are not limited", func(t *testing.T) {
+
+		var limiter *rateLimiter
+		start := time.Now()
+		limiter.wait(t.Context())
+		assert.Less(t, time.Since(start), 10*time.Millisecond)
+	})
+}
+
+func dialError(err error) error {
+	return &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", err)}
+}
+
+func readError(err error) error {
+	return &net.OpError{Op: "read", Net: "tcp", Err: err}
+}
+
+func storeMax(maxValue *atomic.Int32, n int32) {
+	for {
+		current := maxValue.Load()
+		if n <= current || maxValue.CompareAndSwap(current, n) {
+			return
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"github.com/pete911/certinfo/pkg/cert"
+	"sync"
+)
+
+// emitter passes loaded locations to emit function as soon as input is loaded, in ordered mode locations are buffered
+// until all previous inputs are loaded. Emit function is never called concurrently.
+type emitter struct {
+	ordered bool
+	emit    func(cert.CertificateLocation)
+
+	mu      sync.Mutex
+	pending map[int]cert.CertificateLocations
+	next    int
+}
+
+func newEmitter(ordered bool, emit func(cert.CertificateLocation)) *emitter {
+	return &emitter{ordered: ordered, emit: emit, pending: make(map[int]cert.CertificateLocations)}
+}
+
+// done is called when input with the index is loaded
+func (e *emitter) done(i int, locations cert.CertificateLocations) {
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.ordered {
+		e.emitAll(locations)
+		return
+	}
+	e.pending[i] = locations
+	for {
+		next, ok := e.pending[e.next]
+		if !ok {
+			return
+		}
+		delete(e.pending, e.next)
+		e.emitAll(next)
+		e.next++
+	}
+}
+
+func (e *emitter) emitAll(locations cert.CertificateLocations) {
+	for _, location := range locations {
+		e.emit(location)
+	}
+}
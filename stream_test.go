@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/pete911/certinfo/pkg/cert"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmitter_done(t *testing.T) {
+
+	t.Run("given ordered emitter then locations are emitted in input order", func(t *testing.T) {
+
+		var paths []string
+		e := newEmitter(true, func(location cert.CertificateLocation) {
+			paths = append(paths, location.Path)
+		})
+		e.done(2, cert.CertificateLocations{{Path: "c"}})
+		e.done(1, cert.CertificateLocations{{Path: "b1"}, {Path: "b2"}})
+		assert.Empty(t, paths)
+
+		e.done(0, cert.CertificateLocations{{Path: "a"}})
+		e.done(3, nil)
+		e.done(4, cert.CertificateLocations{{Path: "e"}})
+		assert.Equal(t, []string{"a", "b1", "b2", "c", "e"}, paths)
+	})
+
+	t.Run("given unordered emitter then locations are emitted as soon as they are loaded", func(t *testing.T) {
+
+		var paths []string
+		e := newEmitter(false, func(location cert.CertificateLocation) {
+			paths = append(paths, location.Path)
+		})
+		e.done(2, cert.CertificateLocations{{Path: "c"}})
+		assert.Equal(t, []string{"c"}, paths)
+		e.done(0, cert.CertificateLocations{{Path: "a"}})
+		assert.Equal(t, []string{"c", "a"}, paths)
+	})
+}
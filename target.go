@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/pete911/certinfo/pkg/cert"
+	"net"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// target is network address parsed from argument
+type target struct {
+	addr string
+	// protocol used to upgrade connection to TLS, set by URL scheme or protocol option, empty if it is not set
+	protocol   string
+	serverName string
+	// insecure is set only if it is set by target option, otherwise flag value is used
+	insecure *bool
+	timeout  time.Duration
+}
+
+// host returns target host, used to limit connections per host
+func (t target) host() string {
+
+	host, _, err := net.SplitHostPort(t.addr)
+	if err != nil {
+		return t.addr
+	}
+	return host
+}
+
+// networkOptions returns options with target specific settings
+func (t target) networkOptions(options cert.NetworkOptions) cert.NetworkOptions {
+
+	if t.protocol != "" {
+		options.StartTLS = t.protocol
+	}
+	if t.serverName != "" {
+		options.ServerName = t.serverName
+	}
+	if t.insecure != nil {
+		options.InsecureSkipVerify = *t.insecure
+	}
+	// target timeout applies to connect and handshake
+	if t.timeout != 0 {
+		options.ConnectTimeout = t.timeout
+		options.HandshakeTimeout = t.timeout
+	}
+	return options
+}
+
+// schemes maps URL scheme to protocol used to upgrade connection to TLS and default port, schemes with implicit TLS
+// (e.g. https) do not upgrade connection even if the port is well-known STARTTLS port
+var schemes = map[string]struct{ protocol, port string }{
+	"https":      {protocol: cert.StartTLSNone, port: "443"},
+	"ldaps":      {protocol: cert.StartTLSNone, port: "636"},
+	"smtps":      {protocol: cert.StartTLSNone, port: "465"},
+	"imaps":      {protocol: cert.StartTLSNone, port: "993"},
+	"pop3s":      {protocol: cert.StartTLSNone, port: "995"},
+	"ftps":       {protocol: cert.StartTLSNone, port: "990"},
+	"nntps":      {protocol: cert.StartTLSNone, port: "563"},
+	"xmpps":      {protocol: cert.StartTLSNone, port: "5223"},
+	"smtp":       {protocol: "smtp", port: "25"},
+	"imap":       {protocol: "imap", port: "143"},
+	"pop3":       {protocol: "pop3", port: "110"},
+	"ldap":       {protocol: "ldap", port: "389"},
+	"xmpp":       {protocol: "xmpp", port: "5222"},
+	"ftp":        {protocol: "ftp", port: "21"},
+	"nntp":       {protocol: "nntp", port: "119"},
+	"postgres":   {protocol: "postgres", port: "5432"},
+	"postgresql": {protocol: "postgres", port: "5432"},
+	"mysql":      {protocol: "mysql", port: "3306"},
+	"rdp":        {protocol: "rdp", port: "3389"},
+	"mssql":      {protocol: "mssql", port: "1433"},
+	"sqlserver":  {protocol: "mssql", port: "1433"},
+}
+
+// file extensions that are not top level domains, argument like cert.pem is missing file, not a host
+var fileExtensions = []string{".pem", ".crt", ".cer", ".der", ".p7b", ".p7c", ".p12", ".pfx", ".jks", ".jceks", ".key",
+	".yaml", ".yml", ".json", ".txt"}
+
+// parseTarget parses argument as network target, supported formats are host:port, [ipv6]:port, bare host or IP
+// address (default port is used) and URL e.g. https://example.com/path (scheme default port is used if port is not set).
+// Target can have options in query e.g. 10.0.0.5:443?sni=api.example.com&insecure&protocol=smtp, URL query belongs to
+// the application, so URL target options are prefixed e.g. https://example.com/path?page=2&certinfo.sni=api.example.com
+func parseTarget(arg, defaultPort string) (target, error) {
+
+	if strings.Contains(arg, "://") {
+		return parseURLTarget(arg)
+	}
+
+	arg, query, _ := strings.Cut(arg, "?")
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return target{}, fmt.Errorf("invalid options: %w", err)
+	}
+	t := target{}
+	if err := t.setOptions(values); err != nil {
+		return target{}, err
+	}
+
+	host, port, err := net.SplitHostPort(arg)
+	if err != nil {
+		// no port, IPv6 literal can be bracketed or not
+		host, port = strings.TrimSuffix(strings.TrimPrefix(arg, "["), "]"), defaultPort
+	}
+	if err := validateHost(host); err != nil {
+		return target{}, err
+	}
+	if err := validatePort(port); err != nil {
+		return target{}, err
+	}
+	t.addr = net.JoinHostPort(host, port)
+	return t, nil
+}
+
+func parseURLTarget(arg string) (target, error) {
+
+	u, err := url.Parse(arg)
+	if err != nil {
+		return target{}, err
+	}
+	scheme, ok := schemes[strings.ToLower(u.Scheme)]
+	if !ok {
+		return target{}, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err := validateHost(u.Hostname()); err != nil {
+		return target{}, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = scheme.port
+	}
+	if err := validatePort(port); err != nil {
+		return target{}, err
+	}
+	t := target{addr: net.JoinHostPort(u.Hostname(), port), protocol: scheme.protocol}
+	// URL query parameters without the prefix belong to the application and are ignored
+	options := make(url.Values)
+	for key, value := range u.Query() {
+		if option, ok := strings.CutPrefix(key, urlOptionPrefix); ok {
+			options[option] = value
+		}
+	}
+	if err := t.setOptions(options); err != nil {
+		return target{}, err
+	}
+	return t, nil
+}
+
+// urlOptionPrefix is prefix of target options in URL query
+const urlOptionPrefix = "certinfo."
+
+// setOptions sets target options from query values, sni, insecure (value is optional and defaults to true), protocol
+// and timeout are supported
+func (t *target) setOptions(values url.Values) error {
+
+	for key := range values {
+		value := values.Get(key)
+		switch key {
+		case "sni":
+			t.serverName = value
+		case "insecure":
+			insecure := true
+			if value != "" {
+				v, err := strconv.ParseBool(value)
+				if err != nil {
+					return fmt.Errorf("invalid insecure option %s", value)
+				}
+				insecure = v
+			}
+			t.insecure = &insecure
+		case "protocol":
+			t.protocol = value
+		case "timeout":
+			timeout, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("invalid timeout option %s", value)
+			}
+			t.timeout = timeout
+		default:
+			return fmt.Errorf("unknown option %s", key)
+		}
+	}
+	return nil
+}
+
+// parseResolve parses resolve override in host:port:ip format (IPv6 address can be bracketed), returned address is
+// lower case host:port
+func parseResolve(value string) (string, string, error) {
+
+	host, rest, ok := strings.Cut(value, ":")
+	port, ip, ok2 := strings.Cut(rest, ":")
+	if !ok || !ok2 {
+		return "", "", fmt.Errorf("invalid resolve %s: expected host:port:ip", value)
+	}
+	if err := validateHost(host); err != nil {
+		return "", "", fmt.Errorf("invalid resolve %s: %w", value, err)
+	}
+	if err := validatePort(port); err != nil {
+		return "", "", fmt.Errorf("invalid resolve %s: %w", value, err)
+	}
+	parsedIP := net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]"))
+	if parsedIP == nil {
+		return "", "", fmt.Errorf("invalid resolve %s: invalid ip %s", value, ip)
+	}
+	return net.JoinHostPort(strings.ToLower(host), port), parsedIP.String(), nil
+}
+
+func validateHost(host string) error {
+
+	if host == "" {
+		return errors.New("missing host")
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	if slices.Contains(fileExtensions, strings.ToLower(filepath.Ext(host))) {
+		return errors.New("file does not exist")
+	}
+	if len(host) > 253 {
+		return fmt.Errorf("invalid host %s", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if !isHostLabel(label) {
+			return fmt.Errorf("invalid host %s", host)
+		}
+	}
+	return nil
+}
+
+func isHostLabel(label string) bool {
+
+	if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+		return false
+	}
+	for _, c := range label {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+func validatePort(port string) error {
+
+	if port == "" {
+		return errors.New("missing port")
+	}
+	if p, err := strconv.Atoi(port); err != nil || p < 1 || p > 65535 {
+		return fmt.Errorf("invalid port %s", port)
+	}
+	return nil
+}